@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+var ErrAugmentRange = errors.New("Augmentation has to be given as PROBABILITY:MIN:MAX with probability in range [0, 1]")
+
+// AugmentRange is a distortion applied with given probability.
+// Strength of distortion is drawn uniformly from [Min, Max].
+type AugmentRange struct {
+	Probability float64
+	Min         float64
+	Max         float64
+}
+
+// ParseAugmentRange parses range written as PROBABILITY:MIN:MAX
+func ParseAugmentRange(value string) (AugmentRange, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return AugmentRange{}, ErrAugmentRange
+	}
+
+	var numbers [3]float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return AugmentRange{}, ErrAugmentRange
+		}
+		numbers[i] = number
+	}
+
+	r := AugmentRange{Probability: numbers[0], Min: numbers[1], Max: numbers[2]}
+	if r.Probability < 0 || r.Probability > 1 || r.Min > r.Max {
+		return AugmentRange{}, ErrAugmentRange
+	}
+	return r, nil
+}
+
+func (r AugmentRange) String() string {
+	return fmt.Sprintf("%v:%v:%v", r.Probability, r.Min, r.Max)
+}
+
+// Pick tells if distortion should be applied and how strong it should be
+func (r AugmentRange) Pick(rng *rand.Rand) (float64, bool) {
+	// Both numbers are always drawn so one distortion does not change others
+	apply := rng.Float64() < r.Probability
+	value := r.Min + rng.Float64()*(r.Max-r.Min)
+	return value, apply
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParseAugmentRange(t *testing.T) {
+	r, err := ParseAugmentRange("0.5:-10:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (AugmentRange{Probability: 0.5, Min: -10, Max: 10}) {
+		t.Errorf("Unexpected range %v", r)
+	}
+
+	for _, value := range []string{"", "0.5", "0.5:1", "x:1:2", "1.5:0:1", "0.5:2:1"} {
+		if _, err := ParseAugmentRange(value); err != ErrAugmentRange {
+			t.Errorf("%q: expected %v, got %v", value, ErrAugmentRange, err)
+		}
+	}
+}
+
+func TestAugmentRangePick(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	always := AugmentRange{Probability: 1, Min: 2, Max: 3}
+	never := AugmentRange{Probability: 0, Min: 2, Max: 3}
+	for i := 0; i < 100; i++ {
+		if value, ok := always.Pick(rng); !ok || value < 2 || value > 3 {
+			t.Fatalf("Expected value in [2, 3], got %v (%v)", value, ok)
+		}
+		if _, ok := never.Pick(rng); ok {
+			t.Fatal("Expected distortion not to be applied")
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package common
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mrfuxi/neural"
+)
+
+// LoadNN builds network and loads its weights from file. Architecture saved next to weights
+// takes precedence over spec, which is used for new networks and files saved without architecture.
+// Empty file name means there is nothing to load.
+func LoadNN(fileName string, spec ModelSpec) (neural.Evaluator, ModelSpec, error) {
+	if fileName != "" {
+		stored := ModelSpec{}
+		err := LoadSpec(SpecFileName(fileName), &stored)
+		if err == nil {
+			spec = stored
+		} else if !os.IsNotExist(err) {
+			return nil, spec, err
+		}
+	}
+
+	nn, err := spec.Build()
+	if err != nil {
+		return nil, spec, err
+	}
+	if fileName == "" {
+		return nn, spec, nil
+	}
+
+	fn, err := os.Open(fileName)
+	if err != nil {
+		return nil, spec, err
+	}
+	defer fn.Close()
+
+	return nn, spec, neural.Load(nn, fn)
+}
+
+// SaveNN saves weights of network to file and its architecture next to it.
+// Empty file name means network is not saved.
+func SaveNN(fileName string, nn neural.Evaluator, spec ModelSpec) error {
+	if fileName == "" {
+		return nil
+	}
+
+	fn, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer fn.Close()
+
+	if err := neural.Save(nn, fn); err != nil {
+		return err
+	}
+	return SaveSpec(SpecFileName(fileName), spec)
+}
+
+// Argmax returns index of the biggest value
+func Argmax(values []float64) int {
+	best := 0
+	for i, value := range values {
+		if value > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Correctness calculates average cost and accuracy of network over samples
+func Correctness(nn neural.Evaluator, cost neural.Cost, samples []neural.TrainExample) (avgCost float64, accuracy float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	correct := 0
+	for _, sample := range samples {
+		output := nn.Evaluate(sample.Input)
+		avgCost += cost.Cost(output, sample.Output)
+		if Argmax(output) == Argmax(sample.Output) {
+			correct++
+		}
+	}
+
+	avgCost /= float64(len(samples))
+	accuracy = float64(correct) / float64(len(samples))
+	return
+}
+
+// EpocheCallback builds callback reporting cost and accuracy on validation and test data after each epoche.
+// Validation is left out of report when there is no validation data.
+func EpocheCallback(nn neural.Evaluator, cost neural.Cost, validationData, testData []neural.TrainExample) neural.EpocheCallback {
+	return func(epoche int, dt time.Duration) {
+		validationCost, validationAccuracy := Correctness(nn, cost, validationData)
+		testCost, testAccuracy := Correctness(nn, cost, testData)
+		printEpoche(epoche, dt, len(validationData) > 0, validationCost, validationAccuracy, testCost, testAccuracy)
+	}
+}
+
+func printEpoche(epoche int, dt time.Duration, validated bool, validationCost, validationAccuracy, testCost, testAccuracy float64) {
+	validation := ""
+	if validated {
+		validation = fmt.Sprintf("validation cost %.4f, accuracy %.2f%% | ", validationCost, validationAccuracy*100)
+	}
+	fmt.Printf("%3d (%v): %stest cost %.4f, accuracy %.2f%%\n", epoche, dt, validation, testCost, testAccuracy*100)
+}
+
+// TrainNN trains network on samples of trainFile, reporting progress on validation and test samples
+// after each epoche. Samples are kept as pixels and converted to network input in chunks, see TrainingSpec.Chunk.
+// Validation samples are held out of train file as given by training, see TrainingSpec.Splits.
+// In k-fold mode new network is trained on each fold first and nn is trained on all samples at the end.
+// Streamed train file is read from disk on each epoche instead, with validation samples held out at random.
+func TrainNN(nn neural.Evaluator, spec ModelSpec, training TrainingSpec, options neural.TrainOptions, data Dataset, trainFile, testFile string) error {
+	if training.Stream {
+		return trainStream(nn, training, options, data, trainFile, testFile)
+	}
+
+	samples, err := data.Load(trainFile)
+	if err != nil {
+		return err
+	}
+	testSamples, err := data.Load(testFile)
+	if err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(training.Seed))
+	inMemory := func(samples []Sample) func() (SampleReader, error) {
+		if len(samples) == 0 {
+			return nil
+		}
+		return func() (SampleReader, error) { return NewSliceReader(samples), nil }
+	}
+	fitSplit := func(nn neural.Evaluator, split Split) error {
+		train, validation := split.Apply(samples)
+		shuffled := func() (SampleReader, error) {
+			rng.Shuffle(len(train), func(i, j int) { train[i], train[j] = train[j], train[i] })
+			return NewSliceReader(train), nil
+		}
+		return data.fit(nn, options, training.ChunkSize(), len(train), shuffled, inMemory(validation), inMemory(testSamples))
+	}
+
+	strata := make([]string, len(samples))
+	for i, sample := range samples {
+		strata[i] = sample.Stratum
+	}
+	splits := training.Splits(strata)
+	if len(splits) == 1 {
+		return fitSplit(nn, splits[0])
+	}
+
+	sum := 0.0
+	for i, split := range splits {
+		fmt.Printf("Fold %d/%d\n", i+1, len(splits))
+		foldNN, err := spec.Build()
+		if err != nil {
+			return err
+		}
+		if err := fitSplit(foldNN, split); err != nil {
+			return err
+		}
+
+		_, validation := split.Apply(samples)
+		_, accuracy, err := data.Correctness(foldNN, options.Cost, NewSliceReader(validation))
+		if err != nil {
+			return err
+		}
+		sum += accuracy
+	}
+	fmt.Printf("Mean validation accuracy of %d folds: %.2f%%\n", len(splits), sum/float64(len(splits))*100)
+
+	fmt.Println("Training on all data")
+	return fitSplit(nn, Split{Train: splits[0].All()})
+}
+
+// trainStream trains network reading train and test samples from disk on each epoche
+func trainStream(nn neural.Evaluator, training TrainingSpec, options neural.TrainOptions, data Dataset, trainFile, testFile string) error {
+	total, err := data.Count(trainFile)
+	if err != nil {
+		return err
+	}
+	if total < 0 {
+		// File does not store its number of samples, count them
+		if total, err = countSamples(func() (SampleReader, error) { return data.Open(trainFile) }); err != nil {
+			return err
+		}
+	}
+	total -= heldOut(total, training.Validation, training.Seed)
+
+	rng := rand.New(rand.NewSource(training.Seed))
+	train := func() (SampleReader, error) {
+		r, err := data.Open(trainFile)
+		if err != nil {
+			return nil, err
+		}
+		kept := NewHoldOut(r, training.Validation, training.Seed, false)
+		return NewShuffleBuffer(kept, training.ShuffleBufferSize(), rand.New(rand.NewSource(rng.Int63()))), nil
+	}
+	var validation func() (SampleReader, error)
+	if training.Validation > 0 {
+		validation = func() (SampleReader, error) {
+			r, err := data.Open(trainFile)
+			if err != nil {
+				return nil, err
+			}
+			return NewHoldOut(r, training.Validation, training.Seed, true), nil
+		}
+	}
+	test := func() (SampleReader, error) {
+		return data.Open(testFile)
+	}
+	return data.fit(nn, options, training.ChunkSize(), total, train, validation, test)
+}
+
+// RoutineRunner starts n goroutines running routine. Once all of them finish done is called (if not nil).
+// When async is false RoutineRunner blocks until done was called.
+func RoutineRunner(n int, async bool, routine func(), done func()) {
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			routine()
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		if done != nil {
+			done()
+		}
+		close(finished)
+	}()
+
+	if !async {
+		<-finished
+	}
+}
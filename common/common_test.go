@@ -0,0 +1,256 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mrfuxi/neural"
+)
+
+type fixedEvaluator struct {
+	neural.Evaluator
+	output []float64
+}
+
+func (f *fixedEvaluator) Evaluate(input []float64) []float64 {
+	return f.output
+}
+
+var testSpec = ModelSpec{
+	Input:            4,
+	Hidden:           []int{3},
+	Activations:      []string{ActivationSigmoid},
+	Output:           2,
+	OutputActivation: ActivationSoftmax,
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "common")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadNNEmptyPath(t *testing.T) {
+	nn, spec, err := LoadNN("", testSpec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if nn == nil {
+		t.Error("Expected new network to be built")
+	}
+	if spec.String() != testSpec.String() {
+		t.Errorf("Expected spec %v, got %v", testSpec, spec)
+	}
+}
+
+func TestLoadNNMissingFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	if _, _, err := LoadNN(path.Join(dir, "missing.bin"), testSpec); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestSaveNNEmptyPath(t *testing.T) {
+	nn, _ := testSpec.Build()
+	if err := SaveNN("", nn, testSpec); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestSaveLoadNN(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fileName := path.Join(dir, "net.bin")
+
+	saved, _ := testSpec.Build()
+	if err := SaveNN(fileName, saved, testSpec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Architecture saved with network wins over the one given
+	otherSpec := testSpec
+	otherSpec.Hidden = []int{10, 10}
+	loaded, spec, err := LoadNN(fileName, otherSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.String() != testSpec.String() {
+		t.Errorf("Expected spec %v, got %v", testSpec, spec)
+	}
+
+	input := []float64{0.1, 0.5, 0.9, 0.3}
+	expected := saved.Evaluate(input)
+	got := loaded.Evaluate(input)
+	if len(expected) != len(got) {
+		t.Fatalf("Expected %v outputs, got %v", len(expected), len(got))
+	}
+	for i := range expected {
+		if expected[i] != got[i] {
+			t.Errorf("Output %v: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestLoadNNWithoutSpec(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fileName := path.Join(dir, "net.bin")
+
+	saved, _ := testSpec.Build()
+	if err := SaveNN(fileName, saved, testSpec); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(SpecFileName(fileName))
+
+	_, spec, err := LoadNN(fileName, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.String() != testSpec.String() {
+		t.Errorf("Expected given spec %v, got %v", testSpec, spec)
+	}
+}
+
+func TestArgmax(t *testing.T) {
+	testCases := []struct {
+		values   []float64
+		expected int
+	}{
+		{[]float64{1}, 0},
+		{[]float64{0.1, 0.7, 0.2}, 1},
+		{[]float64{0.1, 0.2, 0.7}, 2},
+		{[]float64{0.5, 0.5}, 0},
+	}
+
+	for _, tc := range testCases {
+		if got := Argmax(tc.values); got != tc.expected {
+			t.Errorf("Argmax(%v): expected %v, got %v", tc.values, tc.expected, got)
+		}
+	}
+}
+
+func TestCorrectness(t *testing.T) {
+	nn := &fixedEvaluator{output: []float64{0.2, 0.8}}
+	samples := []neural.TrainExample{
+		{Input: []float64{0}, Output: []float64{0, 1}},
+		{Input: []float64{0}, Output: []float64{0, 1}},
+		{Input: []float64{0}, Output: []float64{1, 0}},
+		{Input: []float64{0}, Output: []float64{0, 1}},
+	}
+
+	_, accuracy := Correctness(nn, neural.NewQuadraticCost(), samples)
+	if accuracy != 0.75 {
+		t.Errorf("Expected accuracy 0.75, got %v", accuracy)
+	}
+
+	cost, accuracy := Correctness(nn, neural.NewQuadraticCost(), nil)
+	if cost != 0 || accuracy != 0 {
+		t.Errorf("Expected zero cost and accuracy for no samples, got %v and %v", cost, accuracy)
+	}
+}
+
+// epocheReport returns line printed by callback after epoche 3
+func epocheReport(t *testing.T, callback neural.EpocheCallback) string {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	callback(3, 0)
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestEpocheCallback(t *testing.T) {
+	nn := &fixedEvaluator{output: []float64{0.2, 0.8}}
+	validation := []neural.TrainExample{{Input: []float64{0}, Output: []float64{0, 1}}}
+	test := []neural.TrainExample{{Input: []float64{0}, Output: []float64{1, 0}}}
+
+	line := epocheReport(t, EpocheCallback(nn, neural.NewQuadraticCost(), validation, test))
+	if !strings.HasPrefix(line, "  3") {
+		t.Errorf("Expected report for epoche 3, got %q", line)
+	}
+	if !strings.Contains(line, "validation cost") || !strings.Contains(line, "accuracy 100.00%") {
+		t.Errorf("Expected validation report, got %q", line)
+	}
+	if !strings.Contains(line, "test cost") || !strings.Contains(line, "accuracy 0.00%") {
+		t.Errorf("Expected test report, got %q", line)
+	}
+
+	line = epocheReport(t, EpocheCallback(nn, neural.NewQuadraticCost(), nil, test))
+	if strings.Contains(line, "validation") || !strings.Contains(line, "test cost") {
+		t.Errorf("Expected only test report without validation data, got %q", line)
+	}
+}
+
+func TestRoutineRunnerSync(t *testing.T) {
+	var calls int32
+	var doneCalls int32
+
+	RoutineRunner(4, false, func() { atomic.AddInt32(&calls, 1) }, func() { atomic.AddInt32(&doneCalls, 1) })
+
+	if calls != 4 {
+		t.Errorf("Expected 4 routines to run, got %v", calls)
+	}
+	if doneCalls != 1 {
+		t.Errorf("Expected done to be called once, got %v", doneCalls)
+	}
+}
+
+func TestRoutineRunnerAsync(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	RoutineRunner(2, true, func() { <-release }, func() { close(finished) })
+
+	select {
+	case <-finished:
+		t.Fatal("Done called before routines finished")
+	default:
+	}
+
+	close(release)
+	<-finished
+}
+
+func TestRoutineRunnerPipeline(t *testing.T) {
+	input := make(chan int, 10)
+	output := make(chan int, 10)
+
+	RoutineRunner(1, true, func() {
+		for i := 0; i < 100; i++ {
+			input <- i
+		}
+	}, func() { close(input) })
+	RoutineRunner(3, true, func() {
+		for i := range input {
+			output <- i * 2
+		}
+	}, func() { close(output) })
+
+	sum := 0
+	RoutineRunner(1, false, func() {
+		for i := range output {
+			sum += i
+		}
+	}, nil)
+
+	if sum != 9900 {
+		t.Errorf("Expected sum 9900, got %v", sum)
+	}
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+	"math/rand"
+)
+
+// Remap builds image of the same size, taking each pixel from img at position returned by src.
+// Position is of pixel center, in the same coordinates as used by BilinearGray.
+func Remap(img *image.Gray, src func(p Point) Point) *image.Gray {
+	bounds := img.Bounds()
+	dst := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := src(Point{float64(x) + 0.5, float64(y) + 0.5})
+			dst.Pix[dst.PixOffset(x, y)] = BilinearGray(img, p)
+		}
+	}
+	return dst
+}
+
+// Warp transforms image with homography mapping points of img to points of result
+func Warp(img *image.Gray, h Homography) (*image.Gray, error) {
+	inverse, err := h.Inverse()
+	if err != nil {
+		return nil, err
+	}
+	return Remap(img, inverse.Apply), nil
+}
+
+// GaussianBlur blurs image with gaussian of given standard deviation in pixels
+func GaussianBlur(img *image.Gray, sigma float64) *image.Gray {
+	bounds := img.Bounds()
+	field := make([]float64, len(img.Pix))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			field[y*bounds.Dx()+x] = float64(img.Pix[y*img.Stride+x])
+		}
+	}
+	smoothField(field, bounds.Dx(), bounds.Dy(), sigma)
+
+	dst := image.NewGray(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			dst.Pix[y*dst.Stride+x] = uint8(math.Min(255, math.Max(0, field[y*bounds.Dx()+x]+0.5)))
+		}
+	}
+	return dst
+}
+
+// smoothField convolves values of width x height field with gaussian, edges are repeated
+func smoothField(field []float64, width, height int, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		} else if v >= max {
+			return max - 1
+		}
+		return v
+	}
+
+	tmp := make([]float64, len(field))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := 0.0
+			for i, k := range kernel {
+				value += k * field[y*width+clamp(x+i-radius, width)]
+			}
+			tmp[y*width+x] = value
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := 0.0
+			for i, k := range kernel {
+				value += k * tmp[clamp(y+i-radius, height)*width+x]
+			}
+			field[y*width+x] = value
+		}
+	}
+}
+
+// Dilate makes bright strokes thicker, taking the brightest pixel within radius
+func Dilate(img *image.Gray, radius int) *image.Gray {
+	return morphology(img, radius, func(a, b uint8) bool { return a > b })
+}
+
+// Erode makes bright strokes thinner, taking the darkest pixel within radius
+func Erode(img *image.Gray, radius int) *image.Gray {
+	return morphology(img, radius, func(a, b uint8) bool { return a < b })
+}
+
+func morphology(img *image.Gray, radius int, better func(a, b uint8) bool) *image.Gray {
+	bounds := img.Bounds()
+	dst := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			best := img.GrayAt(x, y).Y
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if p := (image.Point{x + dx, y + dy}); p.In(bounds) {
+						if v := img.GrayAt(p.X, p.Y).Y; better(v, best) {
+							best = v
+						}
+					}
+				}
+			}
+			dst.Pix[dst.PixOffset(x, y)] = best
+		}
+	}
+	return dst
+}
+
+// ElasticDistortion moves pixels along smooth random field (Simard et al. 2003).
+// Alpha is the biggest move in pixels, sigma controls smoothness of the field.
+func ElasticDistortion(img *image.Gray, alpha, sigma float64, rng *rand.Rand) *image.Gray {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dx := make([]float64, width*height)
+	dy := make([]float64, width*height)
+	for i := range dx {
+		dx[i] = rng.Float64()*2 - 1
+		dy[i] = rng.Float64()*2 - 1
+	}
+	smoothField(dx, width, height, sigma)
+	smoothField(dy, width, height, sigma)
+
+	longest := 0.0
+	for i := range dx {
+		longest = math.Max(longest, math.Hypot(dx[i], dy[i]))
+	}
+	scale := 0.0
+	if longest > 0 {
+		scale = alpha / longest
+	}
+
+	return Remap(img, func(p Point) Point {
+		i := (int(p.Y)-bounds.Min.Y)*width + int(p.X) - bounds.Min.X
+		return Point{p.X + dx[i]*scale, p.Y + dy[i]*scale}
+	})
+}
+
+// SaltAndPepper sets given fraction of pixels to black or white at random
+func SaltAndPepper(img *image.Gray, fraction float64, rng *rand.Rand) *image.Gray {
+	dst := image.NewGray(img.Bounds())
+	copy(dst.Pix, img.Pix)
+
+	bounds := img.Bounds()
+	n := int(fraction*float64(bounds.Dx()*bounds.Dy()) + 0.5)
+	for i := 0; i < n; i++ {
+		x := bounds.Min.X + rng.Intn(bounds.Dx())
+		y := bounds.Min.Y + rng.Intn(bounds.Dy())
+		value := uint8(0)
+		if rng.Intn(2) == 1 {
+			value = 255
+		}
+		dst.Pix[dst.PixOffset(x, y)] = value
+	}
+	return dst
+}
+
+// JPEGRecompress returns image as it looks after saving to JPEG file of given quality (1-100)
+func JPEGRecompress(img *image.Gray, quality int) (*image.Gray, error) {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return ToGray(decoded), nil
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// dotImage returns black image with white square of given size in the middle
+func dotImage(size, dot int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	start := (size - dot) / 2
+	for y := start; y < start+dot; y++ {
+		for x := start; x < start+dot; x++ {
+			img.Pix[img.PixOffset(x, y)] = 255
+		}
+	}
+	return img
+}
+
+func ink(img *image.Gray) int {
+	sum := 0
+	for _, pix := range img.Pix {
+		sum += int(pix)
+	}
+	return sum
+}
+
+func TestWarp(t *testing.T) {
+	img := dotImage(9, 1)
+
+	moved, err := Warp(img, Homography{1, 0, 2, 0, 1, -1, 0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.GrayAt(6, 3).Y != 255 || moved.GrayAt(4, 4).Y != 0 {
+		t.Errorf("Expected dot to be moved by (2, -1), got %v", moved.Pix)
+	}
+
+	if _, err := Warp(img, Homography{}); err != ErrSingular {
+		t.Errorf("Expected %v, got %v", ErrSingular, err)
+	}
+}
+
+func TestGaussianBlur(t *testing.T) {
+	img := dotImage(9, 1)
+
+	blurred := GaussianBlur(img, 1)
+	center := blurred.GrayAt(4, 4).Y
+	if center == 0 || center == 255 {
+		t.Errorf("Expected center to be spread, got %v", center)
+	}
+	if blurred.GrayAt(5, 4).Y == 0 || blurred.GrayAt(5, 4).Y >= center {
+		t.Errorf("Expected neighbour darker than center, got %v and %v", blurred.GrayAt(5, 4).Y, center)
+	}
+
+	if same := GaussianBlur(img, 0); ink(same) != ink(img) {
+		t.Error("Expected no blur for zero sigma")
+	}
+}
+
+func TestDilateErode(t *testing.T) {
+	img := dotImage(9, 3)
+
+	if got := ink(Dilate(img, 1)); got != 25*255 {
+		t.Errorf("Expected 5x5 square after dilation, got %v pixels", got/255)
+	}
+	if got := ink(Erode(img, 1)); got != 255 {
+		t.Errorf("Expected single pixel after erosion, got %v pixels", got/255)
+	}
+}
+
+func TestElasticDistortion(t *testing.T) {
+	img := dotImage(28, 10)
+	rng := rand.New(rand.NewSource(1))
+
+	distorted := ElasticDistortion(img, 2, 4, rng)
+	if ink(distorted) == 0 {
+		t.Fatal("Expected image to keep its content")
+	}
+	changed := 0
+	for i := range img.Pix {
+		if img.Pix[i] != distorted.Pix[i] {
+			changed++
+		}
+	}
+	if changed == 0 {
+		t.Error("Expected image to be distorted")
+	}
+
+	if same := ElasticDistortion(img, 0, 4, rng); ink(same) != ink(img) {
+		t.Error("Expected no distortion for zero alpha")
+	}
+}
+
+func TestSaltAndPepper(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+
+	noisy := SaltAndPepper(img, 0.1, rand.New(rand.NewSource(1)))
+	changed := 0
+	for _, pix := range noisy.Pix {
+		if pix != 128 {
+			changed++
+			if pix != 0 && pix != 255 {
+				t.Errorf("Expected black or white noise, got %v", pix)
+			}
+		}
+	}
+	if changed == 0 || changed > 10 {
+		t.Errorf("Expected up to 10 noisy pixels, got %v", changed)
+	}
+	if img.Pix[0] != 128 {
+		t.Error("Expected original image to be left untouched")
+	}
+}
+
+func TestJPEGRecompress(t *testing.T) {
+	img := dotImage(16, 4)
+
+	recompressed, err := JPEGRecompress(img, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recompressed.Bounds() != img.Bounds() {
+		t.Fatalf("Expected size %v, got %v", img.Bounds(), recompressed.Bounds())
+	}
+	if recompressed.GrayAt(8, 8).Y < 128 || recompressed.GrayAt(0, 0).Y > 128 {
+		t.Error("Expected content to survive recompression")
+	}
+}
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+var ErrSingular = errors.New("Singular matrix")
+var ErrNotEnoughPoints = errors.New("Not enough points")
+
+// Point on a plane
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Dist returns euclidean distance between points
+func (p Point) Dist(o Point) float64 {
+	return math.Hypot(p.X-o.X, p.Y-o.Y)
+}
+
+// Homography is a projective transformation stored as row-major 3x3 matrix
+type Homography [9]float64
+
+// IdentityHomography returns transformation which does not move points
+func IdentityHomography() Homography {
+	return Homography{1, 0, 0, 0, 1, 0, 0, 0, 1}
+}
+
+// Apply transforms point
+func (h Homography) Apply(p Point) Point {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	return Point{
+		X: (h[0]*p.X + h[1]*p.Y + h[2]) / w,
+		Y: (h[3]*p.X + h[4]*p.Y + h[5]) / w,
+	}
+}
+
+// Mul returns transformation applying o first and then h
+func (h Homography) Mul(o Homography) (r Homography) {
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			for k := 0; k < 3; k++ {
+				r[row*3+col] += h[row*3+k] * o[k*3+col]
+			}
+		}
+	}
+	return
+}
+
+// Inverse returns transformation reverting h
+func (h Homography) Inverse() (Homography, error) {
+	det := h[0]*(h[4]*h[8]-h[5]*h[7]) - h[1]*(h[3]*h[8]-h[5]*h[6]) + h[2]*(h[3]*h[7]-h[4]*h[6])
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, ErrSingular
+	}
+
+	inv := Homography{
+		h[4]*h[8] - h[5]*h[7], h[2]*h[7] - h[1]*h[8], h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8], h[0]*h[8] - h[2]*h[6], h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6], h[1]*h[6] - h[0]*h[7], h[0]*h[4] - h[1]*h[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, nil
+}
+
+// FitHomography finds transformation mapping src points onto dst points.
+// With more than 4 points least squares solution is returned.
+func FitHomography(src, dst []Point) (Homography, error) {
+	if len(src) < 4 || len(src) != len(dst) {
+		return Homography{}, ErrNotEnoughPoints
+	}
+
+	srcNorm, srcPoints := normalizePoints(src)
+	dstNorm, dstPoints := normalizePoints(dst)
+
+	var ata [8][9]float64 // Normal equations with right hand side in last column
+	for i := range srcPoints {
+		x, y := srcPoints[i].X, srcPoints[i].Y
+		u, v := dstPoints[i].X, dstPoints[i].Y
+		rows := [2][9]float64{
+			{x, y, 1, 0, 0, 0, -u * x, -u * y, u},
+			{0, 0, 0, x, y, 1, -v * x, -v * y, v},
+		}
+		for _, row := range rows {
+			for r := 0; r < 8; r++ {
+				for c := 0; c < 9; c++ {
+					ata[r][c] += row[r] * row[c]
+				}
+			}
+		}
+	}
+
+	solution, err := solveLinear(ata)
+	if err != nil {
+		return Homography{}, err
+	}
+
+	var h Homography
+	copy(h[:], solution[:])
+	h[8] = 1
+
+	dstDenorm, err := dstNorm.Inverse()
+	if err != nil {
+		return Homography{}, err
+	}
+	return dstDenorm.Mul(h).Mul(srcNorm), nil
+}
+
+// normalizePoints moves points to have centroid at origin and average distance of sqrt(2) from it
+func normalizePoints(points []Point) (Homography, []Point) {
+	var center Point
+	for _, p := range points {
+		center.X += p.X
+		center.Y += p.Y
+	}
+	center.X /= float64(len(points))
+	center.Y /= float64(len(points))
+
+	dist := 0.0
+	for _, p := range points {
+		dist += p.Dist(center)
+	}
+	dist /= float64(len(points))
+
+	scale := 1.0
+	if dist > 0 {
+		scale = math.Sqrt2 / dist
+	}
+
+	norm := Homography{scale, 0, -scale * center.X, 0, scale, -scale * center.Y, 0, 0, 1}
+	normalized := make([]Point, len(points))
+	for i, p := range points {
+		normalized[i] = norm.Apply(p)
+	}
+	return norm, normalized
+}
+
+// solveLinear solves system of linear equations using Gaussian elimination with partial pivoting
+func solveLinear(m [8][9]float64) (x [8]float64, err error) {
+	n := len(m)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return x, ErrSingular
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for c := col; c <= n; c++ {
+				m[row][c] -= factor * m[col][c]
+			}
+		}
+	}
+
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for c := row + 1; c < n; c++ {
+			sum -= m[row][c] * x[c]
+		}
+		x[row] = sum / m[row][row]
+	}
+	return x, nil
+}
+
+// BilinearGray samples grayscale image at sub-pixel position. Points outside of image are black.
+func BilinearGray(img *image.Gray, p Point) uint8 {
+	bounds := img.Bounds()
+	x := p.X - 0.5
+	y := p.Y - 0.5
+	x0 := int(math.Floor(x))
+	y0 := int(math.Floor(y))
+	fx := x - float64(x0)
+	fy := y - float64(y0)
+
+	at := func(x, y int) float64 {
+		if !(image.Point{x, y}.In(bounds)) {
+			return 0
+		}
+		return float64(img.GrayAt(x, y).Y)
+	}
+
+	top := at(x0, y0)*(1-fx) + at(x0+1, y0)*fx
+	bottom := at(x0, y0+1)*(1-fx) + at(x0+1, y0+1)*fx
+	return uint8(math.Min(255, math.Max(0, top*(1-fy)+bottom*fy+0.5)))
+}
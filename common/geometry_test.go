@@ -0,0 +1,97 @@
+package common
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func assertPoint(t *testing.T, expected, got Point) {
+	if expected.Dist(got) > 1e-6 {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFitHomographyCorners(t *testing.T) {
+	src := []Point{{0, 0}, {9, 0}, {9, 9}, {0, 9}}
+	dst := []Point{{10, 20}, {300, 15}, {320, 310}, {5, 290}}
+
+	h, err := FitHomography(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range src {
+		assertPoint(t, dst[i], h.Apply(src[i]))
+	}
+}
+
+func TestFitHomographyLeastSquares(t *testing.T) {
+	expected := Homography{1.2, 0.1, 30, -0.05, 0.9, 12, 0.0005, 0.0002, 1}
+
+	var src, dst []Point
+	for x := 0.0; x <= 9; x++ {
+		for y := 0.0; y <= 9; y++ {
+			p := Point{x * 28, y * 28}
+			src = append(src, p)
+			dst = append(dst, expected.Apply(p))
+		}
+	}
+
+	h, err := FitHomography(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range src {
+		assertPoint(t, dst[i], h.Apply(src[i]))
+	}
+}
+
+func TestFitHomographyNotEnoughPoints(t *testing.T) {
+	_, err := FitHomography([]Point{{0, 0}, {1, 0}, {1, 1}}, []Point{{0, 0}, {1, 0}, {1, 1}})
+	if err != ErrNotEnoughPoints {
+		t.Errorf("Expected %v, got %v", ErrNotEnoughPoints, err)
+	}
+}
+
+func TestHomographyInverse(t *testing.T) {
+	h := Homography{1.2, 0.1, 30, -0.05, 0.9, 12, 0.0005, 0.0002, 1}
+	inv, err := h.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Point{17, 42}
+	assertPoint(t, p, inv.Apply(h.Apply(p)))
+	assertPoint(t, p, h.Mul(inv).Apply(p))
+}
+
+func TestHomographySingular(t *testing.T) {
+	if _, err := (Homography{}).Inverse(); err != ErrSingular {
+		t.Errorf("Expected %v, got %v", ErrSingular, err)
+	}
+}
+
+func TestBilinearGray(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.SetGray(0, 0, color.Gray{Y: 0})
+	img.SetGray(1, 0, color.Gray{Y: 200})
+
+	testCases := []struct {
+		p        Point
+		expected uint8
+	}{
+		{Point{0.5, 0.5}, 0},
+		{Point{1.5, 0.5}, 200},
+		{Point{1, 0.5}, 100},
+		{Point{-5, -5}, 0},
+	}
+
+	for _, tc := range testCases {
+		if got := BilinearGray(img, tc.p); math.Abs(float64(got)-float64(tc.expected)) > 1 {
+			t.Errorf("BilinearGray(%v): expected %v, got %v", tc.p, tc.expected, got)
+		}
+	}
+}
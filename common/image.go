@@ -0,0 +1,95 @@
+package common
+
+import (
+	"image"
+	"image/color"
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
+	"os"
+)
+
+// LoadImage reads PNG or JPEG image from file
+func LoadImage(fileName string) (image.Image, error) {
+	fn, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fn.Close()
+
+	img, _, err := image.Decode(fn)
+	return img, err
+}
+
+// ToGray converts image to grayscale
+func ToGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+
+	bounds := img.Bounds()
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray.Set(x, y, color.GrayModel.Convert(img.At(x, y)))
+		}
+	}
+	return gray
+}
+
+// Invert returns negative of grayscale image
+func Invert(img *image.Gray) *image.Gray {
+	inverted := image.NewGray(img.Bounds())
+	for i, pix := range img.Pix {
+		inverted.Pix[i] = 255 - pix
+	}
+	return inverted
+}
+
+// LightOnDark inverts image with dark ink on light paper, so it looks like training data
+func LightOnDark(gray *image.Gray) *image.Gray {
+	sum := 0
+	for _, pix := range gray.Pix {
+		sum += int(pix)
+	}
+	if len(gray.Pix) > 0 && sum/len(gray.Pix) > 127 {
+		return Invert(gray)
+	}
+	return gray
+}
+
+// Resize scales image to given size averaging all source pixels covered by destination pixel
+func Resize(img image.Image, width, height int) *image.Gray {
+	src := ToGray(img)
+	bounds := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+
+	scaleX := float64(bounds.Dx()) / float64(width)
+	scaleY := float64(bounds.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		y0, y1 := scaledRange(y, scaleY, bounds.Min.Y)
+		for x := 0; x < width; x++ {
+			x0, x1 := scaledRange(x, scaleX, bounds.Min.X)
+
+			sum, cnt := 0, 0
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += int(src.GrayAt(sx, sy).Y)
+					cnt++
+				}
+			}
+			dst.SetGray(x, y, color.Gray{Y: uint8(sum / cnt)})
+		}
+	}
+
+	return dst
+}
+
+func scaledRange(pos int, scale float64, offset int) (int, int) {
+	start := offset + int(float64(pos)*scale)
+	end := offset + int(float64(pos+1)*scale)
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func grayImage(rect image.Rectangle, pix ...uint8) *image.Gray {
+	img := image.NewGray(rect)
+	copy(img.Pix, pix)
+	return img
+}
+
+func TestToGray(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.White)
+	img.Set(1, 0, color.RGBA{R: 255, A: 255})
+
+	gray := ToGray(img)
+	if gray.Bounds() != img.Bounds() || gray.Pix[0] != 255 || gray.Pix[1] != color.GrayModel.Convert(img.At(1, 0)).(color.Gray).Y {
+		t.Errorf("Unexpected grayscale pixels %v", gray.Pix)
+	}
+
+	already := grayImage(image.Rect(0, 0, 1, 1), 7)
+	if ToGray(already) != already {
+		t.Error("Expected grayscale image to be returned as is")
+	}
+}
+
+func TestInvert(t *testing.T) {
+	img := grayImage(image.Rect(0, 0, 3, 1), 0, 100, 255)
+	inverted := Invert(img)
+	if !bytes.Equal(inverted.Pix, []uint8{255, 155, 0}) {
+		t.Errorf("Expected negative pixels, got %v", inverted.Pix)
+	}
+	if img.Pix[0] != 0 {
+		t.Error("Expected original image to be left untouched")
+	}
+}
+
+func TestResize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		img           *image.Gray
+		width, height int
+		expected      []uint8
+	}{
+		{
+			"downscale averages",
+			grayImage(image.Rect(0, 0, 4, 2),
+				0, 100, 10, 10,
+				100, 200, 20, 40),
+			2, 1,
+			[]uint8{100, 20},
+		},
+		{
+			"upscale repeats",
+			grayImage(image.Rect(0, 0, 2, 1), 10, 20),
+			4, 2,
+			[]uint8{
+				10, 10, 20, 20,
+				10, 10, 20, 20,
+			},
+		},
+		{
+			"same size",
+			grayImage(image.Rect(0, 0, 2, 2), 1, 2, 3, 4),
+			2, 2,
+			[]uint8{1, 2, 3, 4},
+		},
+		{
+			"bounds not starting at 0",
+			grayImage(image.Rect(5, 5, 7, 6), 50, 150),
+			1, 1,
+			[]uint8{100},
+		},
+	}
+
+	for _, tc := range testCases {
+		resized := Resize(tc.img, tc.width, tc.height)
+		if resized.Bounds() != image.Rect(0, 0, tc.width, tc.height) {
+			t.Errorf("%v: expected size %vx%v, got %v", tc.name, tc.width, tc.height, resized.Bounds())
+			continue
+		}
+		if !bytes.Equal(resized.Pix, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, resized.Pix)
+		}
+	}
+}
+
+func TestLightOnDark(t *testing.T) {
+	light := grayImage(image.Rect(0, 0, 2, 1), 250, 10)
+	if got := LightOnDark(light); got.Pix[0] != 5 || got.Pix[1] != 245 {
+		t.Errorf("Expected light paper to be inverted, got %v", got.Pix)
+	}
+
+	dark := grayImage(image.Rect(0, 0, 2, 1), 10, 200)
+	if got := LightOnDark(dark); got != dark {
+		t.Errorf("Expected dark paper to be kept, got %v", got.Pix)
+	}
+}
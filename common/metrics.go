@@ -0,0 +1,152 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Confusion is a confusion matrix of classifier. Rows are actual labels, columns predicted ones.
+type Confusion struct {
+	Labels []string
+	Counts [][]int
+}
+
+// NewConfusion creates empty confusion matrix for given labels
+func NewConfusion(labels []string) *Confusion {
+	counts := make([][]int, len(labels))
+	for i := range counts {
+		counts[i] = make([]int, len(labels))
+	}
+	return &Confusion{
+		Labels: labels,
+		Counts: counts,
+	}
+}
+
+// Add counts single classification
+func (c *Confusion) Add(actual, predicted int) {
+	c.Counts[actual][predicted]++
+}
+
+// Total returns number of classified samples
+func (c *Confusion) Total() int {
+	total := 0
+	for _, row := range c.Counts {
+		for _, cnt := range row {
+			total += cnt
+		}
+	}
+	return total
+}
+
+// Correct returns number of correctly classified samples
+func (c *Confusion) Correct() int {
+	correct := 0
+	for i := range c.Counts {
+		correct += c.Counts[i][i]
+	}
+	return correct
+}
+
+// Accuracy returns fraction of correctly classified samples
+func (c *Confusion) Accuracy() float64 {
+	return ratio(c.Correct(), c.Total())
+}
+
+// Support returns number of samples with given actual label
+func (c *Confusion) Support(label int) int {
+	support := 0
+	for _, cnt := range c.Counts[label] {
+		support += cnt
+	}
+	return support
+}
+
+// Precision returns fraction of samples predicted as label which really have it
+func (c *Confusion) Precision(label int) float64 {
+	predicted := 0
+	for i := range c.Counts {
+		predicted += c.Counts[i][label]
+	}
+	return ratio(c.Counts[label][label], predicted)
+}
+
+// Recall returns fraction of samples with label which were predicted as it
+func (c *Confusion) Recall(label int) float64 {
+	return ratio(c.Counts[label][label], c.Support(label))
+}
+
+// F1 returns harmonic mean of precision and recall
+func (c *Confusion) F1(label int) float64 {
+	precision := c.Precision(label)
+	recall := c.Recall(label)
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// Print writes accuracy, confusion matrix and per label metrics
+func (c *Confusion) Print(w io.Writer) {
+	fmt.Fprintf(w, "Accuracy: %.2f%% (%d/%d)\n\n", c.Accuracy()*100, c.Correct(), c.Total())
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "actual \\ predicted\t")
+	for _, label := range c.Labels {
+		fmt.Fprintf(tw, "%v\t", label)
+	}
+	fmt.Fprintln(tw)
+	for i, row := range c.Counts {
+		fmt.Fprintf(tw, "%v\t", c.Labels[i])
+		for _, cnt := range row {
+			fmt.Fprintf(tw, "%d\t", cnt)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "label\tprecision\trecall\tF1\tsupport\t")
+	for i, label := range c.Labels {
+		fmt.Fprintf(tw, "%v\t%.4f\t%.4f\t%.4f\t%d\t\n", label, c.Precision(i), c.Recall(i), c.F1(i), c.Support(i))
+	}
+	tw.Flush()
+}
+
+// PrintComparison writes accuracy and per label F1 of several classifiers side by side.
+// All confusion matrices need to have the same labels.
+func PrintComparison(w io.Writer, names []string, confusions []*Confusion) {
+	if len(confusions) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\t")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%v\t", name)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprint(tw, "accuracy\t")
+	for _, c := range confusions {
+		fmt.Fprintf(tw, "%.4f\t", c.Accuracy())
+	}
+	fmt.Fprintln(tw)
+	for i, label := range confusions[0].Labels {
+		fmt.Fprintf(tw, "F1 %v\t", label)
+		for _, c := range confusions {
+			fmt.Fprintf(tw, "%.4f\t", c.F1(i))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
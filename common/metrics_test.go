@@ -0,0 +1,93 @@
+package common
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func assertFloat(t *testing.T, name string, expected, got float64) {
+	if math.Abs(expected-got) > 1e-9 {
+		t.Errorf("%v: expected %v, got %v", name, expected, got)
+	}
+}
+
+func TestConfusion(t *testing.T) {
+	c := NewConfusion([]string{"a", "b", "c"})
+	// a: 3 correct, 1 as b
+	c.Add(0, 0)
+	c.Add(0, 0)
+	c.Add(0, 0)
+	c.Add(0, 1)
+	// b: 1 correct, 1 as a
+	c.Add(1, 1)
+	c.Add(1, 0)
+	// c: never seen
+
+	if c.Total() != 6 || c.Correct() != 4 {
+		t.Errorf("Expected 4 out of 6 correct, got %v out of %v", c.Correct(), c.Total())
+	}
+	assertFloat(t, "accuracy", 4.0/6, c.Accuracy())
+
+	assertFloat(t, "precision a", 3.0/4, c.Precision(0))
+	assertFloat(t, "recall a", 3.0/4, c.Recall(0))
+	assertFloat(t, "F1 a", 3.0/4, c.F1(0))
+
+	assertFloat(t, "precision b", 1.0/2, c.Precision(1))
+	assertFloat(t, "recall b", 1.0/2, c.Recall(1))
+
+	assertFloat(t, "precision c", 0, c.Precision(2))
+	assertFloat(t, "recall c", 0, c.Recall(2))
+	assertFloat(t, "F1 c", 0, c.F1(2))
+
+	if c.Support(0) != 4 || c.Support(2) != 0 {
+		t.Errorf("Unexpected support %v and %v", c.Support(0), c.Support(2))
+	}
+}
+
+func TestConfusionPrint(t *testing.T) {
+	c := NewConfusion([]string{"a", "b"})
+	c.Add(0, 0)
+	c.Add(1, 0)
+
+	buf := bytes.Buffer{}
+	c.Print(&buf)
+	out := buf.String()
+
+	for _, expected := range []string{
+		"Accuracy: 50.00% (1/2)",
+		"actual \\ predicted a b",
+		"a 1 0",
+		"b 1 0",
+		"a 0.5000 1.0000 0.6667 1",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(out), " "), expected) {
+			t.Errorf("Expected %q in:\n%v", expected, out)
+		}
+	}
+}
+
+func TestPrintComparison(t *testing.T) {
+	first := NewConfusion([]string{"a", "b"})
+	first.Add(0, 0)
+	first.Add(1, 0)
+	second := NewConfusion([]string{"a", "b"})
+	second.Add(0, 0)
+	second.Add(1, 1)
+
+	buf := bytes.Buffer{}
+	PrintComparison(&buf, []string{"first", "second"}, []*Confusion{first, second})
+	out := strings.Join(strings.Fields(buf.String()), " ")
+
+	for _, expected := range []string{
+		"first second",
+		"accuracy 0.5000 1.0000",
+		"F1 a 0.6667 1.0000",
+		"F1 b 0.0000 1.0000",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in:\n%v", expected, buf.String())
+		}
+	}
+}
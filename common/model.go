@@ -0,0 +1,144 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrfuxi/neural"
+	"gopkg.in/yaml.v2"
+)
+
+// Names of supported activation functions
+const (
+	ActivationSigmoid = "sigmoid"
+	ActivationTanh    = "tanh"
+	ActivationLinear  = "linear"
+	ActivationSoftmax = "softmax"
+)
+
+var ErrNoLayers = errors.New("Network needs input and output layer")
+var ErrLayerSize = errors.New("Layer needs at least one neuron")
+var ErrActivations = errors.New("Number of activations does not match number of hidden layers")
+
+// ModelSpec describes architecture of network. It is saved next to network weights.
+type ModelSpec struct {
+	Input  int   `json:"input" yaml:"input"`
+	Hidden []int `json:"hidden" yaml:"hidden"`
+	// Activation of each hidden layer. Single activation is used for all hidden layers.
+	Activations      []string `json:"activations" yaml:"activations"`
+	Output           int      `json:"output" yaml:"output"`
+	OutputActivation string   `json:"output_activation" yaml:"output_activation"`
+	// What outputs of network mean, specific to network. Empty for the only or default target.
+	Target string `json:"target,omitempty" yaml:"target,omitempty"`
+	// Names of labels in order of outputs, when network learns label map of data
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Hyperparameters network was trained with
+	Training *TrainingSpec `json:"training,omitempty" yaml:"training,omitempty"`
+}
+
+func (s ModelSpec) String() string {
+	layers := []string{fmt.Sprint(s.Input)}
+	for i, size := range s.Hidden {
+		layers = append(layers, fmt.Sprintf("%d (%v)", size, s.activation(i)))
+	}
+	layers = append(layers, fmt.Sprintf("%d (%v)", s.Output, s.OutputActivation))
+	return strings.Join(layers, " -> ")
+}
+
+func (s ModelSpec) activation(layer int) string {
+	if len(s.Activations) == 1 {
+		return s.Activations[0]
+	}
+	return s.Activations[layer]
+}
+
+// Validate checks if network can be built from spec
+func (s ModelSpec) Validate() error {
+	if s.Input < 1 || s.Output < 1 {
+		return ErrNoLayers
+	}
+	for _, size := range s.Hidden {
+		if size < 1 {
+			return ErrLayerSize
+		}
+	}
+	if len(s.Hidden) > 0 && len(s.Activations) != 1 && len(s.Activations) != len(s.Hidden) {
+		return ErrActivations
+	}
+	for _, activation := range s.Activations {
+		if _, err := layerFactory(activation); err != nil {
+			return err
+		}
+	}
+	if _, err := layerFactory(s.OutputActivation); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Build creates network with random weights
+func (s ModelSpec) Build() (neural.Evaluator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	sizes := []int{s.Input}
+	var layers []neural.LayerFactory
+	for i, size := range s.Hidden {
+		sizes = append(sizes, size)
+		layer, _ := layerFactory(s.activation(i))
+		layers = append(layers, layer)
+	}
+	sizes = append(sizes, s.Output)
+	layer, _ := layerFactory(s.OutputActivation)
+	layers = append(layers, layer)
+
+	return neural.NewNeuralNetwork(sizes, layers...), nil
+}
+
+func layerFactory(activation string) (neural.LayerFactory, error) {
+	switch activation {
+	case ActivationSigmoid:
+		return neural.NewFullyConnectedLayer(neural.NewSigmoidActivator()), nil
+	case ActivationTanh:
+		return neural.NewFullyConnectedLayer(neural.NewTanhActivator()), nil
+	case ActivationLinear:
+		return neural.NewFullyConnectedLayer(neural.NewLinearActivator()), nil
+	case ActivationSoftmax:
+		return neural.NewFullyConnectedLayer(neural.NewSoftmaxActivator()), nil
+	}
+	return nil, fmt.Errorf("Unknown activation %q", activation)
+}
+
+// LoadSpec reads spec from JSON or YAML file (by extension) on top of values already in spec
+func LoadSpec(fileName string, spec *ModelSpec) error {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(content, spec)
+	default:
+		return json.Unmarshal(content, spec)
+	}
+}
+
+// SaveSpec writes spec as JSON file
+func SaveSpec(fileName string, spec ModelSpec) error {
+	content, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(content, '\n'), 0644)
+}
+
+// SpecFileName returns name of file with spec stored next to network weights
+func SpecFileName(fileName string) string {
+	return fileName + ".json"
+}
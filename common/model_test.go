@@ -0,0 +1,88 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestModelSpecValidate(t *testing.T) {
+	testCases := []struct {
+		spec     ModelSpec
+		expected string
+	}{
+		{testSpec, ""},
+		{ModelSpec{Input: 4, Output: 2, OutputActivation: ActivationSoftmax}, ""},
+		{ModelSpec{Input: 4, Hidden: []int{3, 5}, Activations: []string{ActivationTanh}, Output: 2, OutputActivation: ActivationSigmoid}, ""},
+		{ModelSpec{Input: 4, Hidden: []int{3, 5}, Activations: []string{ActivationTanh, ActivationLinear}, Output: 2, OutputActivation: ActivationSigmoid}, ""},
+		{ModelSpec{Input: 0, Output: 2, OutputActivation: ActivationSoftmax}, ErrNoLayers.Error()},
+		{ModelSpec{Input: 4, Hidden: []int{0}, Activations: []string{ActivationTanh}, Output: 2, OutputActivation: ActivationSoftmax}, ErrLayerSize.Error()},
+		{ModelSpec{Input: 4, Hidden: []int{3, 5, 6}, Activations: []string{ActivationTanh, ActivationTanh}, Output: 2, OutputActivation: ActivationSoftmax}, ErrActivations.Error()},
+		{ModelSpec{Input: 4, Hidden: []int{3}, Activations: []string{"magic"}, Output: 2, OutputActivation: ActivationSoftmax}, `Unknown activation "magic"`},
+		{ModelSpec{Input: 4, Output: 2}, `Unknown activation ""`},
+	}
+
+	for _, tc := range testCases {
+		err := tc.spec.Validate()
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.expected {
+			t.Errorf("Validate(%+v): expected %q, got %q", tc.spec, tc.expected, got)
+		}
+	}
+}
+
+func TestModelSpecString(t *testing.T) {
+	expected := "4 -> 3 (sigmoid) -> 2 (softmax)"
+	if got := testSpec.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestLoadSpec(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"spec.json": `{"hidden": [30, 20], "activations": ["tanh", "sigmoid"]}`,
+		"spec.yaml": "hidden: [30, 20]\nactivations:\n  - tanh\n  - sigmoid\n",
+	}
+
+	for name, content := range files {
+		fileName := path.Join(dir, name)
+		if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		spec := testSpec
+		if err := LoadSpec(fileName, &spec); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "4 -> 30 (tanh) -> 20 (sigmoid) -> 2 (softmax)"
+		if spec.String() != expected {
+			t.Errorf("%v: expected %q, got %q", name, expected, spec.String())
+		}
+	}
+}
+
+func TestSaveSpec(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fileName := path.Join(dir, "spec.json")
+
+	if err := SaveSpec(fileName, testSpec); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := ModelSpec{}
+	if err := LoadSpec(fileName, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.String() != testSpec.String() {
+		t.Errorf("Expected %v, got %v", testSpec, spec)
+	}
+}
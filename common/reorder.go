@@ -0,0 +1,44 @@
+package common
+
+// Reorderer collects items finished out of order, e.g. by parallel routines, and releases them
+// in order of their sequence numbers. Sequence numbers start from 0 and have no gaps.
+type Reorderer struct {
+	pending map[int]interface{}
+	next    int
+}
+
+// NewReorderer returns reorderer waiting for item 0
+func NewReorderer() *Reorderer {
+	return &Reorderer{pending: map[int]interface{}{}}
+}
+
+// Add stores item with its sequence number and returns all items ready to be passed on, in order
+func (r *Reorderer) Add(seq int, item interface{}) []interface{} {
+	r.pending[seq] = item
+
+	var ready []interface{}
+	for {
+		item, ok := r.pending[r.next]
+		if !ok {
+			return ready
+		}
+		delete(r.pending, r.next)
+		r.next++
+		ready = append(ready, item)
+	}
+}
+
+// Reorder passes items received from next to emit in order of their sequence numbers, so output
+// does not depend on which of parallel routines finished first. It returns once next reports no more items.
+func Reorder(next func() (seq int, item interface{}, ok bool), emit func(item interface{})) {
+	reorderer := NewReorderer()
+	for {
+		seq, item, ok := next()
+		if !ok {
+			return
+		}
+		for _, ready := range reorderer.Add(seq, item) {
+			emit(ready)
+		}
+	}
+}
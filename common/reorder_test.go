@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestReorderer(t *testing.T) {
+	r := NewReorderer()
+	var got []interface{}
+	for _, seq := range []int{2, 0, 3, 1, 4} {
+		got = append(got, r.Add(seq, seq*10)...)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("Expected all 5 items, got %v", got)
+	}
+	for i, item := range got {
+		if item.(int) != i*10 {
+			t.Errorf("Expected item %v at %v, got %v", i*10, i, item)
+		}
+	}
+}
+
+func TestReorder(t *testing.T) {
+	items := make(chan int, 5)
+	for _, seq := range []int{3, 1, 0, 4, 2} {
+		items <- seq
+	}
+	close(items)
+
+	var got []int
+	Reorder(func() (int, interface{}, bool) {
+		seq, ok := <-items
+		return seq, seq, ok
+	}, func(item interface{}) {
+		got = append(got, item.(int))
+	})
+
+	if len(got) != 5 {
+		t.Fatalf("Expected all 5 items, got %v", got)
+	}
+	for i, item := range got {
+		if item != i {
+			t.Errorf("Expected item %v at %v, got %v", i, i, item)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package common
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mrfuxi/neural"
+)
+
+// Sample is a compact training sample: pixels as stored in data file and index of expected output.
+// Pixels are converted to network input only when sample is used.
+type Sample struct {
+	Pic   []uint8
+	Label int
+	// Group of samples divided alike when data is split, e.g. label
+	Stratum string
+}
+
+// SampleReader reads samples of data file one by one. Read returns io.EOF after the last sample.
+type SampleReader interface {
+	Read() (Sample, error)
+	Close() error
+}
+
+// Dataset describes data of network: how samples are read and converted to network input and output
+type Dataset struct {
+	// Open opens data file for reading samples
+	Open func(fileName string) (SampleReader, error)
+	// Count returns number of samples of data file, negative when file does not store it
+	Count func(fileName string) (int, error)
+	// Encode converts pixels to network input
+	Encode  func(dst []float64, pic []uint8)
+	Inputs  int
+	Outputs int
+}
+
+// Load reads all samples of data file
+func (d Dataset) Load(fileName string) ([]Sample, error) {
+	r, err := d.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var samples []Sample
+	strata := map[string]string{}
+	for {
+		sample, err := r.Read()
+		if err == io.EOF {
+			return samples, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// All samples of stratum share its name
+		if stratum, ok := strata[sample.Stratum]; ok {
+			sample.Stratum = stratum
+		} else {
+			strata[sample.Stratum] = sample.Stratum
+		}
+		samples = append(samples, sample)
+	}
+}
+
+// Example converts sample to network input and expected output
+func (d Dataset) Example(sample Sample) neural.TrainExample {
+	example := neural.TrainExample{
+		Input:  make([]float64, d.Inputs),
+		Output: make([]float64, d.Outputs),
+	}
+	d.Encode(example.Input, sample.Pic)
+	example.Output[sample.Label] = 1
+	return example
+}
+
+// Examples converts samples to network inputs and expected outputs
+func (d Dataset) Examples(samples []Sample) []neural.TrainExample {
+	examples := make([]neural.TrainExample, len(samples))
+	for i, sample := range samples {
+		examples[i] = d.Example(sample)
+	}
+	return examples
+}
+
+// Correctness calculates average cost and accuracy of network over samples read from r.
+// Only single sample is converted to network input at a time.
+func (d Dataset) Correctness(nn neural.Evaluator, cost neural.Cost, r SampleReader) (avgCost float64, accuracy float64, err error) {
+	input := make([]float64, d.Inputs)
+	desired := make([]float64, d.Outputs)
+	count, correct := 0, 0
+	for {
+		sample, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		d.Encode(input, sample.Pic)
+		for i := range desired {
+			desired[i] = 0
+		}
+		desired[sample.Label] = 1
+
+		output := nn.Evaluate(input)
+		avgCost += cost.Cost(output, desired)
+		if Argmax(output) == sample.Label {
+			correct++
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, 0, nil
+	}
+	return avgCost / float64(count), float64(correct) / float64(count), nil
+}
+
+type sliceReader struct {
+	samples []Sample
+}
+
+// NewSliceReader returns reader of samples kept in memory
+func NewSliceReader(samples []Sample) SampleReader {
+	return &sliceReader{samples: samples}
+}
+
+func (r *sliceReader) Read() (Sample, error) {
+	if len(r.samples) == 0 {
+		return Sample{}, io.EOF
+	}
+	sample := r.samples[0]
+	r.samples = r.samples[1:]
+	return sample, nil
+}
+
+func (r *sliceReader) Close() error {
+	return nil
+}
+
+type shuffleBuffer struct {
+	r      SampleReader
+	rng    *rand.Rand
+	size   int
+	buffer []Sample
+	eof    bool
+}
+
+// NewShuffleBuffer returns reader passing samples of r in random order. Only size samples
+// are kept in memory, so samples further apart in r than that are not mixed.
+func NewShuffleBuffer(r SampleReader, size int, rng *rand.Rand) SampleReader {
+	return &shuffleBuffer{r: r, rng: rng, size: size}
+}
+
+func (b *shuffleBuffer) Read() (Sample, error) {
+	for !b.eof && len(b.buffer) < b.size {
+		sample, err := b.r.Read()
+		if err == io.EOF {
+			b.eof = true
+		} else if err != nil {
+			return Sample{}, err
+		} else {
+			b.buffer = append(b.buffer, sample)
+		}
+	}
+	if len(b.buffer) == 0 {
+		return Sample{}, io.EOF
+	}
+
+	i := b.rng.Intn(len(b.buffer))
+	last := len(b.buffer) - 1
+	sample := b.buffer[i]
+	b.buffer[i] = b.buffer[last]
+	b.buffer = b.buffer[:last]
+	return sample, nil
+}
+
+func (b *shuffleBuffer) Close() error {
+	return b.r.Close()
+}
+
+type holdOut struct {
+	r          SampleReader
+	rng        *rand.Rand
+	fraction   float64
+	validation bool
+}
+
+// NewHoldOut returns reader passing random fraction of samples of r (validation)
+// or all other samples. Readers with the same seed hold out the same samples.
+func NewHoldOut(r SampleReader, fraction float64, seed int64, validation bool) SampleReader {
+	return &holdOut{r: r, rng: rand.New(rand.NewSource(seed)), fraction: fraction, validation: validation}
+}
+
+func (h *holdOut) Read() (Sample, error) {
+	for {
+		sample, err := h.r.Read()
+		if err != nil {
+			return sample, err
+		}
+		if (h.rng.Float64() < h.fraction) == h.validation {
+			return sample, nil
+		}
+	}
+}
+
+func (h *holdOut) Close() error {
+	return h.r.Close()
+}
+
+// heldOut returns number of samples held out of count samples by hold-out readers with the same seed
+func heldOut(count int, fraction float64, seed int64) int {
+	rng := rand.New(rand.NewSource(seed))
+	held := 0
+	for i := 0; i < count; i++ {
+		if rng.Float64() < fraction {
+			held++
+		}
+	}
+	return held
+}
+
+// trainerPool hands out the same trainers to each neural.Train call, so their state
+// (e.g. momentum) carries over between chunks of streamed samples
+type trainerPool struct {
+	factory  neural.TrainerFactory
+	mu       sync.Mutex
+	trainers []neural.Trainer
+	next     int
+}
+
+func (p *trainerPool) Factory(network neural.Evaluator, cost neural.CostDerivative) neural.Trainer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next == len(p.trainers) {
+		p.trainers = append(p.trainers, p.factory(network, cost))
+	}
+	trainer := p.trainers[p.next]
+	p.next++
+	return trainer
+}
+
+// rewind lets next neural.Train call get trainers from the first one
+func (p *trainerPool) rewind() {
+	p.mu.Lock()
+	p.next = 0
+	p.mu.Unlock()
+}
+
+// fit trains network for all epoches of options on total samples read from newly opened reader on each epoche,
+// reporting each epoche on validation (nil when there is none) and test samples. Samples are converted to network input in chunks
+// of whole mini-batches, so only single chunk of them is kept as float64 at a time. Trainers live for
+// the whole run and regularization is scaled, so each chunk is trained as part of all train samples.
+func (d Dataset) fit(nn neural.Evaluator, options neural.TrainOptions, chunk, total int, train, validation, test func() (SampleReader, error)) error {
+	if total == 0 {
+		return nil
+	}
+	if batch := options.MiniBatchSize; batch > 0 && chunk > batch {
+		chunk -= chunk % batch
+	}
+
+	epoches := options.Epochs
+	regularization := options.Regularization
+	pool := &trainerPool{factory: options.TrainerFactory}
+	options.Epochs = 1
+	options.TrainerFactory = pool.Factory
+	options.EpocheCallback = func(int, time.Duration) {}
+
+	trainChunk := func(samples []Sample) {
+		options.Regularization = regularization * float64(len(samples)) / float64(total)
+		pool.rewind()
+		neural.Train(nn, d.Examples(samples), options)
+	}
+	correctness := func(open func() (SampleReader, error)) (float64, float64, error) {
+		if open == nil {
+			return 0, 0, nil
+		}
+		r, err := open()
+		if err != nil {
+			return 0, 0, err
+		}
+		defer r.Close()
+		return d.Correctness(nn, options.Cost, r)
+	}
+
+	for epoche := 1; epoche <= epoches; epoche++ {
+		t0 := time.Now()
+		r, err := train()
+		if err != nil {
+			return err
+		}
+		err = readChunks(r, chunk, trainChunk)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		dt := time.Since(t0)
+
+		validationCost, validationAccuracy, err := correctness(validation)
+		if err != nil {
+			return err
+		}
+		testCost, testAccuracy, err := correctness(test)
+		if err != nil {
+			return err
+		}
+		printEpoche(epoche, dt, validation != nil, validationCost, validationAccuracy, testCost, testAccuracy)
+	}
+	return nil
+}
+
+// readChunks passes samples read from r to fn in chunks of up to size samples
+func readChunks(r SampleReader, size int, fn func(samples []Sample)) error {
+	samples := make([]Sample, 0, size)
+	for {
+		sample, err := r.Read()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == nil {
+			samples = append(samples, sample)
+		}
+
+		if len(samples) == size || (err == io.EOF && len(samples) > 0) {
+			fn(samples)
+			samples = samples[:0]
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// countSamples returns number of samples of newly opened reader
+func countSamples(open func() (SampleReader, error)) (int, error) {
+	r, err := open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	count := 0
+	err = readChunks(r, 1, func([]Sample) { count++ })
+	return count, err
+}
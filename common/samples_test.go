@@ -0,0 +1,145 @@
+package common
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/mrfuxi/neural"
+)
+
+func numberedSamples(count int) []Sample {
+	samples := make([]Sample, count)
+	for i := range samples {
+		samples[i] = Sample{Pic: []uint8{uint8(i)}, Label: i % 2}
+	}
+	return samples
+}
+
+func readSamples(t *testing.T, r SampleReader) []Sample {
+	var samples []Sample
+	for {
+		sample, err := r.Read()
+		if err == io.EOF {
+			return samples
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, sample)
+	}
+}
+
+func TestShuffleBuffer(t *testing.T) {
+	samples := readSamples(t, NewShuffleBuffer(NewSliceReader(numberedSamples(100)), 10, rand.New(rand.NewSource(1))))
+	if len(samples) != 100 {
+		t.Fatalf("Expected all 100 samples, got %v", len(samples))
+	}
+
+	seen := map[uint8]bool{}
+	moved := false
+	for i, sample := range samples {
+		seen[sample.Pic[0]] = true
+		if int(sample.Pic[0]) != i {
+			moved = true
+		}
+		// Sample can not be read before it gets into buffer
+		if int(sample.Pic[0]) > i+9 {
+			t.Errorf("Sample %v read too early at %v", sample.Pic[0], i)
+		}
+	}
+	if len(seen) != 100 || !moved {
+		t.Errorf("Expected shuffled samples, got %v", samples)
+	}
+}
+
+func TestHoldOut(t *testing.T) {
+	train := readSamples(t, NewHoldOut(NewSliceReader(numberedSamples(250)), 0.2, 5, false))
+	validation := readSamples(t, NewHoldOut(NewSliceReader(numberedSamples(250)), 0.2, 5, true))
+
+	if len(train)+len(validation) != 250 {
+		t.Fatalf("Expected every sample once, got %v and %v", len(train), len(validation))
+	}
+	if len(validation) < 30 || len(validation) > 70 {
+		t.Errorf("Expected about 50 validation samples, got %v", len(validation))
+	}
+
+	if count := heldOut(250, 0.2, 5); count != len(validation) {
+		t.Errorf("Expected %v samples to be held out, counted %v", len(validation), count)
+	}
+
+	held := map[uint8]bool{}
+	for _, sample := range validation {
+		held[sample.Pic[0]] = true
+	}
+	for _, sample := range train {
+		if held[sample.Pic[0]] {
+			t.Errorf("Sample %v both in train and validation", sample.Pic[0])
+		}
+	}
+}
+
+func TestDatasetCorrectness(t *testing.T) {
+	data := Dataset{
+		Encode:  func(dst []float64, pic []uint8) { dst[0] = float64(pic[0]) },
+		Inputs:  1,
+		Outputs: 2,
+	}
+	nn := &fixedEvaluator{output: []float64{0.2, 0.8}}
+
+	_, accuracy, err := data.Correctness(nn, neural.NewQuadraticCost(), NewSliceReader(numberedSamples(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accuracy != 0.5 {
+		t.Errorf("Expected accuracy 0.5, got %v", accuracy)
+	}
+
+	example := data.Example(Sample{Pic: []uint8{7}, Label: 1})
+	if example.Input[0] != 7 || example.Output[0] != 0 || example.Output[1] != 1 {
+		t.Errorf("Unexpected example %+v", example)
+	}
+}
+
+type countingTrainer struct {
+	processed int
+}
+
+func (c *countingTrainer) Process(sample neural.TrainExample, weightUpdates *neural.WeightUpdates) {
+	c.processed++
+}
+
+func TestTrainerPool(t *testing.T) {
+	created := 0
+	pool := &trainerPool{factory: func(network neural.Evaluator, cost neural.CostDerivative) neural.Trainer {
+		created++
+		return &countingTrainer{}
+	}}
+
+	first := pool.Factory(nil, nil)
+	second := pool.Factory(nil, nil)
+	pool.rewind()
+	if pool.Factory(nil, nil) != first || pool.Factory(nil, nil) != second {
+		t.Error("Expected the same trainers after rewind")
+	}
+	if created != 2 {
+		t.Errorf("Expected 2 trainers to be created, got %v", created)
+	}
+}
+
+func TestReadChunks(t *testing.T) {
+	var sizes []int
+	err := readChunks(NewSliceReader(numberedSamples(25)), 10, func(samples []Sample) {
+		sizes = append(sizes, len(samples))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+		t.Errorf("Expected chunks of 10, 10 and 5 samples, got %v", sizes)
+	}
+
+	count, err := countSamples(func() (SampleReader, error) { return NewSliceReader(numberedSamples(25)), nil })
+	if err != nil || count != 25 {
+		t.Errorf("Expected 25 samples, got %v (%v)", count, err)
+	}
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Split divides samples, given by their indexes, into train and validation ones
+type Split struct {
+	Train      []int
+	Validation []int
+}
+
+// Apply returns train and validation samples of split
+func (s Split) Apply(samples []Sample) (train, validation []Sample) {
+	for _, i := range s.Train {
+		train = append(train, samples[i])
+	}
+	for _, i := range s.Validation {
+		validation = append(validation, samples[i])
+	}
+	return
+}
+
+// All returns indexes of all samples of split
+func (s Split) All() []int {
+	return append(append([]int{}, s.Train...), s.Validation...)
+}
+
+// groupStrata returns shuffled indexes of samples of each stratum, in order of strata names
+func groupStrata(strata []string, rng *rand.Rand) [][]int {
+	byName := map[string][]int{}
+	for i, stratum := range strata {
+		byName[stratum] = append(byName[stratum], i)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := make([][]int, 0, len(names))
+	for _, name := range names {
+		group := byName[name]
+		shuffle(group, rng)
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func shuffle(indexes []int, rng *rand.Rand) {
+	rng.Shuffle(len(indexes), func(i, j int) { indexes[i], indexes[j] = indexes[j], indexes[i] })
+}
+
+// StratifiedSplit holds out random fraction of samples of each stratum for validation.
+// Strata holds stratum (e.g. label) of each sample.
+func StratifiedSplit(strata []string, fraction float64, rng *rand.Rand) Split {
+	split := Split{}
+	for _, group := range groupStrata(strata, rng) {
+		held := int(math.Floor(float64(len(group))*fraction + 0.5))
+		split.Validation = append(split.Validation, group[:held]...)
+		split.Train = append(split.Train, group[held:]...)
+	}
+	shuffle(split.Train, rng)
+	shuffle(split.Validation, rng)
+	return split
+}
+
+// StratifiedFolds deals random samples of each stratum into k folds of similar size.
+// Each of k splits validates on single fold and trains on the rest.
+func StratifiedFolds(strata []string, k int, rng *rand.Rand) []Split {
+	folds := make([][]int, k)
+	next := 0
+	for _, group := range groupStrata(strata, rng) {
+		for _, i := range group {
+			folds[next] = append(folds[next], i)
+			next = (next + 1) % k
+		}
+	}
+
+	splits := make([]Split, k)
+	for i := range splits {
+		for j, fold := range folds {
+			if i == j {
+				splits[i].Validation = append(splits[i].Validation, fold...)
+			} else {
+				splits[i].Train = append(splits[i].Train, fold...)
+			}
+		}
+		shuffle(splits[i].Train, rng)
+		shuffle(splits[i].Validation, rng)
+	}
+	return splits
+}
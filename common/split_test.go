@@ -0,0 +1,109 @@
+package common
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// testStrata returns 30 samples of stratum a and 10 of stratum b, in that order
+func testStrata() []string {
+	var strata []string
+	for i := 0; i < 40; i++ {
+		if i < 30 {
+			strata = append(strata, "a")
+		} else {
+			strata = append(strata, "b")
+		}
+	}
+	return strata
+}
+
+func countStrata(strata []string, indexes []int) map[string]int {
+	counts := map[string]int{}
+	for _, i := range indexes {
+		counts[strata[i]]++
+	}
+	return counts
+}
+
+func assertPartition(t *testing.T, split Split, size int) {
+	all := append(append([]int{}, split.Train...), split.Validation...)
+	sort.Ints(all)
+	if len(all) != size {
+		t.Fatalf("Expected %v samples, got %v", size, len(all))
+	}
+	for i, index := range all {
+		if i != index {
+			t.Fatalf("Expected each sample exactly once, got %v", all)
+		}
+	}
+}
+
+func TestStratifiedSplit(t *testing.T) {
+	strata := testStrata()
+	split := StratifiedSplit(strata, 0.2, rand.New(rand.NewSource(1)))
+	assertPartition(t, split, len(strata))
+
+	counts := countStrata(strata, split.Validation)
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("Expected 6 samples of a and 2 of b held out, got %v", counts)
+	}
+
+	// Held out samples are not just the tail
+	tail := 0
+	for _, i := range split.Validation {
+		if i >= 32 {
+			tail++
+		}
+	}
+	if tail == len(split.Validation) {
+		t.Errorf("Expected shuffled validation samples, got %v", split.Validation)
+	}
+
+	again := StratifiedSplit(strata, 0.2, rand.New(rand.NewSource(1)))
+	for i := range split.Validation {
+		if split.Validation[i] != again.Validation[i] {
+			t.Fatal("Expected the same seed to give the same split")
+		}
+	}
+
+	if none := StratifiedSplit(strata, 0, rand.New(rand.NewSource(1))); len(none.Validation) != 0 {
+		t.Errorf("Expected nothing held out, got %v", none.Validation)
+	}
+}
+
+func TestStratifiedFolds(t *testing.T) {
+	strata := testStrata()
+	splits := StratifiedFolds(strata, 5, rand.New(rand.NewSource(1)))
+	if len(splits) != 5 {
+		t.Fatalf("Expected 5 splits, got %v", len(splits))
+	}
+
+	validated := map[int]int{}
+	for _, split := range splits {
+		assertPartition(t, split, len(strata))
+		if counts := countStrata(strata, split.Validation); counts["a"] != 6 || counts["b"] != 2 {
+			t.Errorf("Expected 6 samples of a and 2 of b in fold, got %v", counts)
+		}
+		for _, i := range split.Validation {
+			validated[i]++
+		}
+	}
+	if len(validated) != len(strata) {
+		t.Errorf("Expected each sample validated once, got %v", validated)
+	}
+}
+
+func TestTrainingSpecSplits(t *testing.T) {
+	training := testTraining
+	training.Validation = 0.1
+	if splits := training.Splits(testStrata()); len(splits) != 1 || len(splits[0].Validation) != 4 {
+		t.Errorf("Expected single split with 4 held out samples, got %v", splits)
+	}
+
+	training.Folds = 4
+	if splits := training.Splits(testStrata()); len(splits) != 4 {
+		t.Errorf("Expected 4 splits, got %v", len(splits))
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/mrfuxi/neural"
+)
+
+// Names of supported trainers
+const (
+	TrainerBackprop   = "backprop"
+	TrainerRandomized = "randomized"
+)
+
+// DefaultNoise is fraction of input turned white by randomized trainer when none is given
+const DefaultNoise = 0.1
+
+type randTrainer struct {
+	BaseTrainer neural.Trainer
+	Sample      neural.TrainExample
+	Noise       float64
+	Noisy       []int
+	rng         *rand.Rand
+}
+
+// NewRandomizedTrainer returns trainer factory wrapping backpropagation trainer, which on each pass
+// turns white random fraction of input (noise) of samples. Only samples of noisy classes
+// (indexes of expected output) are changed, all samples if there are none.
+// Trainers get their own random numbers generators, seeded one after another from seed.
+func NewRandomizedTrainer(seed int64, noise float64, noisy ...int) neural.TrainerFactory {
+	rng := rand.New(rand.NewSource(seed))
+	mu := sync.Mutex{}
+	return func(network neural.Evaluator, cost neural.CostDerivative) neural.Trainer {
+		mu.Lock()
+		trainerSeed := rng.Int63()
+		mu.Unlock()
+		return &randTrainer{
+			BaseTrainer: neural.NewBackpropagationTrainer(network, cost),
+			Noise:       noise,
+			Noisy:       noisy,
+			rng:         rand.New(rand.NewSource(trainerSeed)),
+		}
+	}
+}
+
+func (r *randTrainer) Process(sample neural.TrainExample, weightUpdates *neural.WeightUpdates) {
+	if !r.isNoisy(sample) {
+		r.BaseTrainer.Process(sample, weightUpdates)
+		return
+	}
+
+	if len(r.Sample.Input) != len(sample.Input) || len(r.Sample.Output) != len(sample.Output) {
+		r.Sample.Input = make([]float64, len(sample.Input))
+		r.Sample.Output = make([]float64, len(sample.Output))
+	}
+	copy(r.Sample.Input, sample.Input)
+	copy(r.Sample.Output, sample.Output)
+
+	for i := 0; i < int(float64(len(r.Sample.Input))*r.Noise); i++ {
+		r.Sample.Input[r.rng.Intn(len(r.Sample.Input))] = 1
+	}
+
+	r.BaseTrainer.Process(r.Sample, weightUpdates)
+}
+
+func (r *randTrainer) isNoisy(sample neural.TrainExample) bool {
+	if len(r.Noisy) == 0 {
+		return true
+	}
+	for _, class := range r.Noisy {
+		if class < len(sample.Output) && sample.Output[class] == 1 {
+			return true
+		}
+	}
+	return false
+}
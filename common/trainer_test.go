@@ -0,0 +1,82 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/mrfuxi/neural"
+)
+
+type recordingTrainer struct {
+	samples []neural.TrainExample
+}
+
+func (r *recordingTrainer) Process(sample neural.TrainExample, weightUpdates *neural.WeightUpdates) {
+	r.samples = append(r.samples, neural.TrainExample{
+		Input:  append([]float64{}, sample.Input...),
+		Output: append([]float64{}, sample.Output...),
+	})
+}
+
+func white(input []float64) int {
+	count := 0
+	for _, v := range input {
+		if v == 1 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRandomizedTrainer(t *testing.T) {
+	base := &recordingTrainer{}
+	trainer := NewRandomizedTrainer(1, 0.5, 2)(nil, nil).(*randTrainer)
+	trainer.BaseTrainer = base
+
+	empty := neural.TrainExample{Input: make([]float64, 100), Output: []float64{0, 0, 1}}
+	other := neural.TrainExample{Input: make([]float64, 100), Output: []float64{1, 0, 0}}
+	trainer.Process(empty, nil)
+	trainer.Process(other, nil)
+
+	if len(base.samples) != 2 {
+		t.Fatalf("Expected 2 processed samples, got %v", len(base.samples))
+	}
+	if got := white(base.samples[0].Input); got == 0 || got > 50 {
+		t.Errorf("Expected up to 50 white pixels in noisy sample, got %v", got)
+	}
+	if got := white(base.samples[1].Input); got != 0 {
+		t.Errorf("Expected sample of other class untouched, got %v white pixels", got)
+	}
+	if white(empty.Input) != 0 {
+		t.Error("Expected original sample to be left untouched")
+	}
+
+	all := NewRandomizedTrainer(1, 0.5)(nil, nil).(*randTrainer)
+	all.BaseTrainer = base
+	all.Process(other, nil)
+	if got := white(base.samples[2].Input); got == 0 {
+		t.Error("Expected noise in all samples without noisy classes")
+	}
+}
+
+func TestRandomizedTrainerSeed(t *testing.T) {
+	noisyInput := func(seed int64) []float64 {
+		base := &recordingTrainer{}
+		trainer := NewRandomizedTrainer(seed, 0.2)(nil, nil).(*randTrainer)
+		trainer.BaseTrainer = base
+		trainer.Process(neural.TrainExample{Input: make([]float64, 100), Output: []float64{1}}, nil)
+		return base.samples[0].Input
+	}
+
+	first, second, other := noisyInput(3), noisyInput(3), noisyInput(4)
+	same, differs := true, false
+	for i := range first {
+		same = same && first[i] == second[i]
+		differs = differs || first[i] != other[i]
+	}
+	if !same {
+		t.Error("Expected the same noise for the same seed")
+	}
+	if !differs {
+		t.Error("Expected different noise for other seed")
+	}
+}
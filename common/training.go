@@ -0,0 +1,178 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/mrfuxi/neural"
+)
+
+// Names of supported cost functions
+const (
+	CostCrossEntropy  = "cross-entropy"
+	CostLogLikelihood = "log-likelihood"
+	CostQuadratic     = "quadratic"
+)
+
+var ErrEpochs = errors.New("Number of epochs has to be positive")
+var ErrMiniBatchSize = errors.New("Mini batch size has to be positive")
+var ErrLearningRate = errors.New("Learning rate has to be positive")
+var ErrRegularization = errors.New("Regularization can not be negative")
+var ErrMomentum = errors.New("Momentum has to be in range [0, 1)")
+var ErrNoise = errors.New("Noise has to be in range [0, 1]")
+var ErrValidation = errors.New("Validation fraction has to be in range [0, 1)")
+var ErrFolds = errors.New("Number of folds has to be at least 2, or 0 to turn k-fold off")
+var ErrChunk = errors.New("Chunk size can not be negative")
+var ErrShuffleBuffer = errors.New("Shuffle buffer size can not be negative")
+var ErrStreamFolds = errors.New("Streamed train data can not be cross validated")
+
+// Defaults used when sizes are not given
+const (
+	DefaultChunk         = 10000
+	DefaultShuffleBuffer = 10000
+)
+
+// TrainingSpec holds hyperparameters of training. It is saved next to network weights.
+type TrainingSpec struct {
+	Epochs         int     `json:"epochs" yaml:"epochs"`
+	MiniBatchSize  int     `json:"batch" yaml:"batch"`
+	LearningRate   float64 `json:"lr" yaml:"lr"`
+	Regularization float64 `json:"l2" yaml:"l2"`
+	Momentum       float64 `json:"momentum" yaml:"momentum"`
+	Cost           string  `json:"cost" yaml:"cost"`
+	// Trainer adding noise on the fly or plain backpropagation (default)
+	Trainer string `json:"trainer,omitempty" yaml:"trainer,omitempty"`
+	// Fraction of input turned white by randomized trainer
+	Noise float64 `json:"noise,omitempty" yaml:"noise,omitempty"`
+	// Fraction of train data held out for validation, taken from each label alike
+	Validation float64 `json:"validation" yaml:"validation"`
+	// Number of folds of k-fold cross validation, 0 turns it off
+	Folds int `json:"folds,omitempty" yaml:"folds,omitempty"`
+	// Seed of shuffling train data before it is split and of noise added by randomized trainer
+	Seed int64 `json:"seed,omitempty" yaml:"seed,omitempty"`
+	// Number of samples converted to network input at once, 0 for DefaultChunk
+	Chunk int `json:"chunk,omitempty" yaml:"chunk,omitempty"`
+	// Read train data from disk on each epoche instead of keeping it in memory
+	Stream bool `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// Number of streamed samples shuffled together, 0 for DefaultShuffleBuffer
+	ShuffleBuffer int `json:"shuffle_buffer,omitempty" yaml:"shuffle_buffer,omitempty"`
+}
+
+func (t TrainingSpec) String() string {
+	s := fmt.Sprintf(
+		"epochs %d, batch %d, lr %v, l2 %v, momentum %v, cost %v",
+		t.Epochs, t.MiniBatchSize, t.LearningRate, t.Regularization, t.Momentum, t.Cost,
+	)
+	if t.Trainer == TrainerRandomized {
+		s += fmt.Sprintf(", trainer %v (noise %v)", t.Trainer, t.Noise)
+	}
+	if t.Folds > 0 {
+		s += fmt.Sprintf(", %d folds", t.Folds)
+	} else if t.Validation > 0 {
+		s += fmt.Sprintf(", validation %v", t.Validation)
+	}
+	if t.Stream {
+		s += fmt.Sprintf(", streamed (shuffle buffer %d)", t.ShuffleBufferSize())
+	}
+	return s
+}
+
+// Validate checks if hyperparameters make sense
+func (t TrainingSpec) Validate() error {
+	switch {
+	case t.Epochs < 1:
+		return ErrEpochs
+	case t.MiniBatchSize < 1:
+		return ErrMiniBatchSize
+	case t.LearningRate <= 0:
+		return ErrLearningRate
+	case t.Regularization < 0:
+		return ErrRegularization
+	case t.Momentum < 0 || t.Momentum >= 1:
+		return ErrMomentum
+	case t.Noise < 0 || t.Noise > 1:
+		return ErrNoise
+	case t.Validation < 0 || t.Validation >= 1:
+		return ErrValidation
+	case t.Folds < 0 || t.Folds == 1:
+		return ErrFolds
+	case t.Chunk < 0:
+		return ErrChunk
+	case t.ShuffleBuffer < 0:
+		return ErrShuffleBuffer
+	case t.Stream && t.Folds > 0:
+		return ErrStreamFolds
+	}
+	_, err := t.TrainOptions()
+	return err
+}
+
+// ChunkSize returns number of samples converted to network input at once
+func (t TrainingSpec) ChunkSize() int {
+	if t.Chunk == 0 {
+		return DefaultChunk
+	}
+	return t.Chunk
+}
+
+// ShuffleBufferSize returns number of streamed samples shuffled together
+func (t TrainingSpec) ShuffleBufferSize() int {
+	if t.ShuffleBuffer == 0 {
+		return DefaultShuffleBuffer
+	}
+	return t.ShuffleBuffer
+}
+
+// Splits divides samples into train and validation ones: k splits in k-fold mode,
+// otherwise single split holding out validation fraction. Strata holds stratum of each sample,
+// every stratum is divided alike. Same seed gives the same splits.
+func (t TrainingSpec) Splits(strata []string) []Split {
+	rng := rand.New(rand.NewSource(t.Seed))
+	if t.Folds > 1 {
+		return StratifiedFolds(strata, t.Folds, rng)
+	}
+	return []Split{StratifiedSplit(strata, t.Validation, rng)}
+}
+
+// TrainerFactory returns factory of chosen trainer. Randomized trainer adds noise, seeded by Seed,
+// only to samples of noisy classes, to all samples if there are none.
+func (t TrainingSpec) TrainerFactory(noisy ...int) (neural.TrainerFactory, error) {
+	switch t.Trainer {
+	case TrainerBackprop, "":
+		return neural.NewBackpropagationTrainer, nil
+	case TrainerRandomized:
+		return NewRandomizedTrainer(t.Seed, t.Noise, noisy...), nil
+	}
+	return nil, fmt.Errorf("Unknown trainer %q", t.Trainer)
+}
+
+// TrainOptions converts hyperparameters to options of neural.Train.
+// Trainer adds noise to samples of noisy classes, see TrainerFactory.
+// EpocheCallback is left for caller to set.
+func (t TrainingSpec) TrainOptions(noisy ...int) (neural.TrainOptions, error) {
+	factory, err := t.TrainerFactory(noisy...)
+	if err != nil {
+		return neural.TrainOptions{}, err
+	}
+	options := neural.TrainOptions{
+		Epochs:         t.Epochs,
+		MiniBatchSize:  t.MiniBatchSize,
+		LearningRate:   t.LearningRate,
+		Regularization: t.Regularization,
+		Momentum:       t.Momentum,
+		TrainerFactory: factory,
+	}
+
+	switch t.Cost {
+	case CostCrossEntropy:
+		options.Cost = neural.NewCrossEntropyCost()
+	case CostLogLikelihood:
+		options.Cost = neural.NewLogLikelihoodCost()
+	case CostQuadratic:
+		options.Cost = neural.NewQuadraticCost()
+	default:
+		return options, fmt.Errorf("Unknown cost %q", t.Cost)
+	}
+	return options, nil
+}
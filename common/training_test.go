@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+)
+
+var testTraining = TrainingSpec{
+	Epochs:         5,
+	MiniBatchSize:  10,
+	LearningRate:   0.01,
+	Regularization: 2,
+	Momentum:       0.9,
+	Cost:           CostCrossEntropy,
+}
+
+func TestTrainingSpecValidate(t *testing.T) {
+	testCases := []struct {
+		update   func(t *TrainingSpec)
+		expected string
+	}{
+		{func(t *TrainingSpec) {}, ""},
+		{func(t *TrainingSpec) { t.Cost = CostLogLikelihood }, ""},
+		{func(t *TrainingSpec) { t.Cost = CostQuadratic; t.Momentum = 0 }, ""},
+		{func(t *TrainingSpec) { t.Epochs = 0 }, ErrEpochs.Error()},
+		{func(t *TrainingSpec) { t.MiniBatchSize = -1 }, ErrMiniBatchSize.Error()},
+		{func(t *TrainingSpec) { t.LearningRate = 0 }, ErrLearningRate.Error()},
+		{func(t *TrainingSpec) { t.Regularization = -0.1 }, ErrRegularization.Error()},
+		{func(t *TrainingSpec) { t.Momentum = 1 }, ErrMomentum.Error()},
+		{func(t *TrainingSpec) { t.Cost = "hinge" }, `Unknown cost "hinge"`},
+		{func(t *TrainingSpec) { t.Trainer = TrainerRandomized; t.Noise = 0.2 }, ""},
+		{func(t *TrainingSpec) { t.Trainer = TrainerRandomized; t.Noise = 1.5 }, ErrNoise.Error()},
+		{func(t *TrainingSpec) { t.Trainer = "genetic" }, `Unknown trainer "genetic"`},
+		{func(t *TrainingSpec) { t.Validation = 0.2; t.Folds = 5 }, ""},
+		{func(t *TrainingSpec) { t.Validation = 1 }, ErrValidation.Error()},
+		{func(t *TrainingSpec) { t.Folds = 1 }, ErrFolds.Error()},
+		{func(t *TrainingSpec) { t.Stream = true; t.Chunk = 100; t.ShuffleBuffer = 1000 }, ""},
+		{func(t *TrainingSpec) { t.Chunk = -1 }, ErrChunk.Error()},
+		{func(t *TrainingSpec) { t.ShuffleBuffer = -1 }, ErrShuffleBuffer.Error()},
+		{func(t *TrainingSpec) { t.Stream = true; t.Folds = 5 }, ErrStreamFolds.Error()},
+	}
+
+	for _, tc := range testCases {
+		training := testTraining
+		tc.update(&training)
+
+		err := training.Validate()
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.expected {
+			t.Errorf("Validate(%v): expected %q, got %q", training, tc.expected, got)
+		}
+	}
+}
+
+func TestTrainingSpecTrainOptions(t *testing.T) {
+	options, err := testTraining.TrainOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.Epochs != 5 || options.MiniBatchSize != 10 || options.LearningRate != 0.01 ||
+		options.Regularization != 2 || options.Momentum != 0.9 {
+		t.Errorf("Unexpected options %+v", options)
+	}
+	if options.Cost == nil || options.TrainerFactory == nil {
+		t.Error("Expected cost and trainer to be set")
+	}
+}
+
+func TestTrainingSpecString(t *testing.T) {
+	expected := "epochs 5, batch 10, lr 0.01, l2 2, momentum 0.9, cost cross-entropy"
+	if got := testTraining.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	randomized := testTraining
+	randomized.Trainer = TrainerRandomized
+	randomized.Noise = 0.1
+	if got := randomized.String(); got != expected+", trainer randomized (noise 0.1)" {
+		t.Errorf("Unexpected %q", got)
+	}
+}
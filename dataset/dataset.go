@@ -0,0 +1,260 @@
+// Package dataset holds things shared by all data files: pixel layout and header.
+//
+// Pixels of a record are stored as grayscale values, row by row (row-major), starting from the
+// top left corner. That is the same layout as used by MNIST. Files written before version
+// marker was introduced store pixels column by column and are reported as VersionLegacy.
+//
+// Header starts with version marker and number of records, followed by JSON description of records:
+// their kind, size of images, label map (names of labels in order of their indexes, so networks
+// know what their outputs mean) and parameters they were generated with.
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// VersionLegacy files have no version marker and pixels stored column by column
+	VersionLegacy = 0
+	// VersionRowMajor files have pixels stored row by row
+	VersionRowMajor = 1
+	// VersionLabels files have label map in header
+	VersionLabels = 2
+	// VersionHeader files have full description of records in header
+	VersionHeader = 3
+	// Version written by generators
+	Version = VersionHeader
+)
+
+// Kinds of records
+const (
+	KindDigit = "digit"
+	KindGrid  = "grid"
+)
+
+// CountUnknown is number of records of files written without it
+const CountUnknown = -1
+
+var magic = []byte("DIGITDAT")
+
+var ErrUpToDate = errors.New("File already in current version")
+
+// ErrVersion is returned for files written by newer version of generators
+type ErrVersion int
+
+func (e ErrVersion) Error() string {
+	return fmt.Sprintf("Unsupported data file version %d (supported up to %d)", int(e), Version)
+}
+
+// Header describes data file
+type Header struct {
+	Version int `json:"-"`
+	// Number of records, CountUnknown for files written before VersionHeader
+	Count int `json:"-"`
+	// Kind of records, empty for files written before VersionHeader
+	Kind string `json:"kind"`
+	// Size of images in pixels, 0 for files written before VersionHeader
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Names of labels in order of their indexes, nil for files written before VersionLabels
+	Labels []string `json:"labels"`
+	// Parameters records were generated with, e.g. seed
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// countOffset is position of number of records in file, it is stored once all records are written
+var countOffset = int64(len(magic) + 2)
+
+// Check returns error if header does not describe records of kind with images of size x size pixels.
+// Files written before VersionHeader are not checked.
+func (h Header) Check(kind string, size int) error {
+	if h.Kind != "" && h.Kind != kind {
+		return fmt.Errorf("Data file holds %s records, expected %s", h.Kind, kind)
+	}
+	if h.Width != 0 && (h.Width != size || h.Height != size) {
+		return fmt.Errorf("Data file holds %dx%d images, expected %dx%d", h.Width, h.Height, size, size)
+	}
+	return nil
+}
+
+// WriteHeader writes version marker, number of records and description of records. Records follow it.
+func WriteHeader(w io.Writer, header Header) error {
+	body, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(Version)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, int64(header.Count)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(body))); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// SetCount stores number of records in header written at the beginning of w
+func SetCount(w io.WriterAt, count int) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(count))
+	_, err := w.WriteAt(buf, countOffset)
+	return err
+}
+
+// ReadHeader reads header if present. Returned reader should be used to read records.
+func ReadHeader(r io.Reader) (Header, io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	prefix, err := buffered.Peek(len(magic))
+	if err == io.EOF || (err == nil && !bytes.Equal(prefix, magic)) {
+		return Header{Version: VersionLegacy, Count: CountUnknown}, buffered, nil
+	} else if err != nil {
+		return Header{}, nil, err
+	}
+	if _, err := buffered.Discard(len(magic)); err != nil {
+		return Header{}, nil, err
+	}
+
+	var version uint16
+	if err := binary.Read(buffered, binary.BigEndian, &version); err != nil {
+		return Header{}, nil, err
+	}
+	if version > Version {
+		return Header{}, nil, ErrVersion(version)
+	}
+	header := Header{Version: int(version), Count: CountUnknown}
+	switch {
+	case version < VersionLabels:
+		return header, buffered, nil
+	case version == VersionLabels:
+		header.Labels, err = readLabels(buffered)
+		return header, buffered, err
+	}
+
+	var count int64
+	if err := binary.Read(buffered, binary.BigEndian, &count); err != nil {
+		return Header{}, nil, err
+	}
+	var length uint32
+	if err := binary.Read(buffered, binary.BigEndian, &length); err != nil {
+		return Header{}, nil, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(buffered, body); err != nil {
+		return Header{}, nil, err
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		return Header{}, nil, err
+	}
+	header.Count = int(count)
+	return header, buffered, nil
+}
+
+// readLabels reads label map of VersionLabels header
+func readLabels(r io.Reader) ([]string, error) {
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	labels := make([]string, count)
+	for i := range labels {
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		label := make([]byte, length)
+		if _, err := io.ReadFull(r, label); err != nil {
+			return nil, err
+		}
+		labels[i] = string(label)
+	}
+	return labels, nil
+}
+
+// FixLayout converts pixels read from file of given version to row-major layout
+func FixLayout(version int, pic []uint8, size int) {
+	if version == VersionLegacy {
+		Transpose(pic, size)
+	}
+}
+
+// Transpose swaps rows with columns of square image
+func Transpose(pic []uint8, size int) {
+	for y := 0; y < size; y++ {
+		for x := y + 1; x < size; x++ {
+			pic[y*size+x], pic[x*size+y] = pic[x*size+y], pic[y*size+x]
+		}
+	}
+}
+
+// ImageToPic converts top left part of image to grayscale pixels in row-major layout
+func ImageToPic(img image.Image, pic []uint8, size int) {
+	bounds := img.Bounds()
+	pos := 0
+	for y := bounds.Min.Y; y < bounds.Min.Y+size; y++ {
+		for x := bounds.Min.X; x < bounds.Min.X+size; x++ {
+			clr := img.At(x, y)
+			grayColor := color.GrayModel.Convert(clr).(color.Gray)
+			pic[pos] = grayColor.Y
+			pos++
+		}
+	}
+}
+
+// PicToImage converts row-major pixels to image
+func PicToImage(pic []uint8, size int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	copy(img.Pix, pic)
+	return img
+}
+
+// Rewrite replaces content of file with output of convert.
+// Original file is left untouched when convert fails.
+func Rewrite(fileName string, convert func(r io.Reader, w io.Writer) error) error {
+	src, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	if err := dst.Chmod(info.Mode()); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := convert(src, dst); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Rename(dst.Name(), fileName)
+}
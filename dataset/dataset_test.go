@@ -0,0 +1,207 @@
+package dataset
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	buf := bytes.Buffer{}
+	labels := []string{"0", "1", "blank", "ą"}
+	written := Header{Count: 42, Kind: KindDigit, Width: 28, Height: 28, Labels: labels, Params: map[string]string{"seed": "7"}}
+	if err := WriteHeader(&buf, written); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("records")
+
+	header, r, err := ReadHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != Version || header.Count != 42 || header.Kind != KindDigit || header.Width != 28 || header.Height != 28 {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	if strings.Join(header.Labels, ",") != strings.Join(labels, ",") {
+		t.Errorf("Expected labels %v, got %v", labels, header.Labels)
+	}
+	if header.Params["seed"] != "7" {
+		t.Errorf("Expected generation parameters, got %v", header.Params)
+	}
+	rest, _ := ioutil.ReadAll(r)
+	if string(rest) != "records" {
+		t.Errorf("Expected records to follow header, got %q", rest)
+	}
+}
+
+func TestHeaderLabels(t *testing.T) {
+	buf := bytes.NewBuffer(append(append([]byte{}, magic...), 0, VersionLabels, 0, 2, 0, 1, 'A', 0, 5))
+	buf.WriteString("blankrecords")
+
+	header, r, err := ReadHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != VersionLabels || header.Count != CountUnknown || header.Kind != "" {
+		t.Errorf("Expected label map version without description, got %+v", header)
+	}
+	if strings.Join(header.Labels, ",") != "A,blank" {
+		t.Errorf("Expected labels A and blank, got %v", header.Labels)
+	}
+	if rest, _ := ioutil.ReadAll(r); string(rest) != "records" {
+		t.Errorf("Expected records to follow header, got %q", rest)
+	}
+}
+
+func TestHeaderCheck(t *testing.T) {
+	testCases := []struct {
+		header   Header
+		expected string
+	}{
+		{Header{Kind: KindDigit, Width: 28, Height: 28}, ""},
+		{Header{}, ""},
+		{Header{Kind: KindGrid, Width: 28, Height: 28}, "Data file holds grid records, expected digit"},
+		{Header{Kind: KindDigit, Width: 32, Height: 32}, "Data file holds 32x32 images, expected 28x28"},
+	}
+
+	for _, tc := range testCases {
+		err := tc.header.Check(KindDigit, 28)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.expected {
+			t.Errorf("Check(%+v): expected %q, got %q", tc.header, tc.expected, got)
+		}
+	}
+}
+
+func TestHeaderLegacy(t *testing.T) {
+	for _, content := range []string{"", "abc", "legacy gob stream"} {
+		header, r, err := ReadHeader(bytes.NewBufferString(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Version != VersionLegacy || header.Labels != nil || header.Count != CountUnknown {
+			t.Errorf("Expected legacy version without labels for %q, got %+v", content, header)
+		}
+
+		rest, _ := ioutil.ReadAll(r)
+		if string(rest) != content {
+			t.Errorf("Expected %q to be read back, got %q", content, rest)
+		}
+	}
+}
+
+func TestHeaderRowMajor(t *testing.T) {
+	buf := bytes.NewBuffer(append(append([]byte{}, magic...), 0, VersionRowMajor))
+	buf.WriteString("records")
+
+	header, r, err := ReadHeader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != VersionRowMajor || header.Labels != nil {
+		t.Errorf("Expected row-major version without labels, got %+v", header)
+	}
+	if rest, _ := ioutil.ReadAll(r); string(rest) != "records" {
+		t.Errorf("Expected records to follow header, got %q", rest)
+	}
+}
+
+func TestHeaderNewerVersion(t *testing.T) {
+	buf := bytes.NewBuffer(append(append([]byte{}, magic...), 0, Version+1))
+
+	if _, _, err := ReadHeader(buf); err != ErrVersion(Version+1) {
+		t.Errorf("Expected %v, got %v", ErrVersion(Version+1), err)
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	pic := []uint8{
+		1, 2, 3,
+		4, 5, 6,
+		7, 8, 9,
+	}
+	Transpose(pic, 3)
+
+	expected := []uint8{
+		1, 4, 7,
+		2, 5, 8,
+		3, 6, 9,
+	}
+	if !bytes.Equal(pic, expected) {
+		t.Errorf("Expected %v, got %v", expected, pic)
+	}
+
+	FixLayout(Version, pic, 3)
+	if !bytes.Equal(pic, expected) {
+		t.Errorf("Current version should not be changed, got %v", pic)
+	}
+}
+
+func TestImageToPic(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.SetGray(1, 0, color.Gray{Y: 10}) // Top right
+	img.SetGray(0, 1, color.Gray{Y: 20}) // Bottom left
+
+	pic := make([]uint8, 4)
+	ImageToPic(img, pic, 2)
+	if !bytes.Equal(pic, []uint8{0, 10, 20, 0}) {
+		t.Errorf("Expected row-major pixels, got %v", pic)
+	}
+
+	back := PicToImage(pic, 2)
+	if back.GrayAt(1, 0).Y != 10 || back.GrayAt(0, 1).Y != 20 {
+		t.Errorf("Expected image to be restored, got %v", back.Pix)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "data.dat")
+	if err := ioutil.WriteFile(fileName, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	errConvert := errors.New("convert failed")
+	err = Rewrite(fileName, func(r io.Reader, w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errConvert
+	})
+	if err != errConvert {
+		t.Errorf("Expected %v, got %v", errConvert, err)
+	}
+	if content, _ := ioutil.ReadFile(fileName); string(content) != "original" {
+		t.Errorf("Expected original content to be kept, got %q", content)
+	}
+
+	err = Rewrite(fileName, func(r io.Reader, w io.Writer) error {
+		content, _ := ioutil.ReadAll(r)
+		_, err := w.Write(bytes.ToUpper(content))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(fileName); string(content) != "ORIGINAL" {
+		t.Errorf("Expected converted content, got %q", content)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected temporary files to be removed, got %v files", len(files))
+	}
+}
@@ -0,0 +1,318 @@
+package dataset
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Magic numbers of IDX files: unsigned bytes with 3 (images) or 1 (labels) dimensions
+const (
+	idxImages = 0x00000803
+	idxLabels = 0x00000801
+)
+
+var ErrIDXLabel = errors.New("Label index does not fit in IDX label file (0-255)")
+
+// IDXWriter writes images and their labels as gzip compressed IDX files, the format of MNIST.
+// Label of image is its index in label map.
+type IDXWriter struct {
+	files   [2]*os.File
+	images  *gzip.Writer
+	labels  *gzip.Writer
+	count   int
+	written int
+}
+
+// CreateIDX creates IDX files for count images of size x size pixels
+func CreateIDX(imagesFile, labelsFile string, count, size int) (*IDXWriter, error) {
+	w := &IDXWriter{count: count}
+	var err error
+	if w.files[0], err = os.Create(imagesFile); err != nil {
+		return nil, err
+	}
+	if w.files[1], err = os.Create(labelsFile); err != nil {
+		w.files[0].Close()
+		os.Remove(imagesFile)
+		return nil, err
+	}
+	w.images = gzip.NewWriter(w.files[0])
+	w.labels = gzip.NewWriter(w.files[1])
+
+	err = binary.Write(w.images, binary.BigEndian, []uint32{idxImages, uint32(count), uint32(size), uint32(size)})
+	if err == nil {
+		err = binary.Write(w.labels, binary.BigEndian, []uint32{idxLabels, uint32(count)})
+	}
+	if err != nil {
+		w.closeFiles()
+		os.Remove(imagesFile)
+		os.Remove(labelsFile)
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes row-major pixels of image and index of its label
+func (w *IDXWriter) Write(pic []uint8, label int) error {
+	if label < 0 || label > 255 {
+		return ErrIDXLabel
+	}
+	if _, err := w.images.Write(pic); err != nil {
+		return err
+	}
+	if _, err := w.labels.Write([]byte{uint8(label)}); err != nil {
+		return err
+	}
+	w.written++
+	return nil
+}
+
+// Close flushes files, returning error if number of written images differs from declared one
+func (w *IDXWriter) Close() error {
+	errImages := w.images.Close()
+	errLabels := w.labels.Close()
+	if err := w.closeFiles(); err != nil {
+		return err
+	}
+	switch {
+	case errImages != nil:
+		return errImages
+	case errLabels != nil:
+		return errLabels
+	case w.written != w.count:
+		return fmt.Errorf("Written %d images to IDX file, expected %d", w.written, w.count)
+	}
+	return nil
+}
+
+func (w *IDXWriter) closeFiles() error {
+	err0 := w.files[0].Close()
+	err1 := w.files[1].Close()
+	if err0 != nil {
+		return err0
+	}
+	return err1
+}
+
+// IDXReader reads images and their labels from IDX files, gzip compressed or not
+type IDXReader struct {
+	// Number of images and their size in pixels
+	Count, Rows, Cols int
+
+	files  [2]*os.File
+	images io.Reader
+	labels io.Reader
+	read   int
+}
+
+// OpenIDX opens IDX files of images and their labels
+func OpenIDX(imagesFile, labelsFile string) (*IDXReader, error) {
+	r := &IDXReader{}
+	var err error
+	if r.files[0], err = os.Open(imagesFile); err != nil {
+		return nil, err
+	}
+	if r.files[1], err = os.Open(labelsFile); err != nil {
+		r.files[0].Close()
+		return nil, err
+	}
+	if err := r.readHeaders(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *IDXReader) readHeaders() error {
+	var err error
+	if r.images, err = decompress(r.files[0]); err != nil {
+		return err
+	}
+	if r.labels, err = decompress(r.files[1]); err != nil {
+		return err
+	}
+
+	var images [4]uint32
+	if err := binary.Read(r.images, binary.BigEndian, &images); err != nil {
+		return err
+	}
+	if images[0] != idxImages {
+		return fmt.Errorf("Not an IDX file of images, magic number %#08x", images[0])
+	}
+	var labels [2]uint32
+	if err := binary.Read(r.labels, binary.BigEndian, &labels); err != nil {
+		return err
+	}
+	if labels[0] != idxLabels {
+		return fmt.Errorf("Not an IDX file of labels, magic number %#08x", labels[0])
+	}
+	if images[1] != labels[1] {
+		return fmt.Errorf("IDX files hold %d images and %d labels", images[1], labels[1])
+	}
+	r.Count, r.Rows, r.Cols = int(images[1]), int(images[2]), int(images[3])
+	return nil
+}
+
+// decompress returns reader of content of file, gunzipped when file is gzip compressed
+func decompress(file io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(file)
+	prefix, err := buffered.Peek(2)
+	if err == nil && prefix[0] == 0x1f && prefix[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// Read reads row-major pixels of next image into pic and returns index of its label.
+// Returns io.EOF after the last image.
+func (r *IDXReader) Read(pic []uint8) (int, error) {
+	if r.read == r.Count {
+		return 0, io.EOF
+	}
+	if _, err := io.ReadFull(r.images, pic[:r.Rows*r.Cols]); err != nil {
+		return 0, err
+	}
+	var label [1]uint8
+	if _, err := io.ReadFull(r.labels, label[:]); err != nil {
+		return 0, err
+	}
+	r.read++
+	return int(label[0]), nil
+}
+
+// Close closes IDX files
+func (r *IDXReader) Close() error {
+	err0 := r.files[0].Close()
+	err1 := r.files[1].Close()
+	if err0 != nil {
+		return err0
+	}
+	return err1
+}
+
+// WriteLabelMap writes label map as text file, one label per line.
+// IDX files store only indexes of labels, so it is kept next to them.
+func WriteLabelMap(fileName string, labels []string) error {
+	return ioutil.WriteFile(fileName, []byte(strings.Join(labels, "\n")+"\n"), 0644)
+}
+
+// ReadLabelMap reads label map written by WriteLabelMap
+func ReadLabelMap(fileName string) ([]string, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			labels = append(labels, line)
+		}
+	}
+	return labels, nil
+}
+
+var ErrIncomplete = errors.New("Data file has no label map or number of records, migrate it first")
+
+// ExportIDX writes records of data file of kind as IDX files of images and labels.
+// Decode reads next record and returns its row-major pixels and index of its label.
+// Returns label map of data file.
+func ExportIDX(fileName, kind string, size int, imagesFile, labelsFile string, decode func(r *Reader) ([]uint8, int, error)) ([]string, error) {
+	records, err := Open(fileName, kind, size)
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+	if records.Header.Labels == nil || records.Header.Count == CountUnknown {
+		return nil, ErrIncomplete
+	}
+
+	w, err := CreateIDX(imagesFile, labelsFile, records.Header.Count, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeIDX(w, records, decode); err != nil {
+		os.Remove(imagesFile)
+		os.Remove(labelsFile)
+		return nil, err
+	}
+	return records.Header.Labels, nil
+}
+
+// writeIDX writes all records decoded from data file to IDX files and closes them, see ExportIDX
+func writeIDX(w *IDXWriter, records *Reader, decode func(r *Reader) ([]uint8, int, error)) error {
+	for {
+		pic, label, err := decode(records)
+		if err == io.EOF {
+			return w.Close()
+		} else if err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Write(pic, label); err != nil {
+			w.Close()
+			return err
+		}
+	}
+}
+
+// ImportIDX writes images of IDX files to data file described by header. Labels of IDX files are indexes
+// in label map of given number of labels. Transposed images (stored column by column, like EMNIST)
+// are converted to row-major. Record converts row-major pixels and index of label to record of data file.
+func ImportIDX(imagesFile, labelsFile, fileName string, labels int, header Header, transpose bool, record func(pic []uint8, label int) interface{}) error {
+	idx, err := OpenIDX(imagesFile, labelsFile)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	if idx.Rows != header.Height || idx.Cols != header.Width {
+		return fmt.Errorf("IDX files hold %dx%d images, expected %dx%d", idx.Cols, idx.Rows, header.Width, header.Height)
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := writeIDXRecords(idx, file, labels, header, transpose, record); err != nil {
+		file.Close()
+		os.Remove(fileName)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(fileName)
+		return err
+	}
+	return nil
+}
+
+// writeIDXRecords writes records made of images of IDX files as data file, see ImportIDX
+func writeIDXRecords(idx *IDXReader, file io.Writer, labels int, header Header, transpose bool, record func(pic []uint8, label int) interface{}) error {
+	w, err := NewWriter(file, header)
+	if err != nil {
+		return err
+	}
+
+	pic := make([]uint8, idx.Rows*idx.Cols)
+	for {
+		label, err := idx.Read(pic)
+		if err == io.EOF {
+			return w.Close()
+		} else if err != nil {
+			return err
+		}
+		if label >= labels {
+			return fmt.Errorf("IDX label %d out of label map of %d labels", label, labels)
+		}
+		if transpose {
+			Transpose(pic, idx.Rows)
+		}
+		if err := w.Encode(record(pic, label)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestIDX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	imagesFile, labelsFile := path.Join(dir, "images.gz"), path.Join(dir, "labels.gz")
+	w, err := CreateIDX(imagesFile, labelsFile, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]uint8{1, 2, 3, 4}, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]uint8{5, 6, 7, 8}, 256); err != ErrIDXLabel {
+		t.Errorf("Expected %v, got %v", ErrIDXLabel, err)
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Expected missing image to be reported")
+	}
+
+	w, err = CreateIDX(imagesFile, labelsFile, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]uint8{1, 2, 3, 4}, 7)
+	w.Write([]uint8{5, 6, 7, 8}, 0)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenIDX(imagesFile, labelsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Count != 2 || r.Rows != 2 || r.Cols != 2 {
+		t.Errorf("Unexpected dimensions %v x %v x %v", r.Count, r.Rows, r.Cols)
+	}
+	pic := make([]uint8, 4)
+	if label, err := r.Read(pic); err != nil || label != 7 || !bytes.Equal(pic, []uint8{1, 2, 3, 4}) {
+		t.Errorf("Unexpected first image %v with label %v (%v)", pic, label, err)
+	}
+	if label, err := r.Read(pic); err != nil || label != 0 || !bytes.Equal(pic, []uint8{5, 6, 7, 8}) {
+		t.Errorf("Unexpected second image %v with label %v (%v)", pic, label, err)
+	}
+	if _, err := r.Read(pic); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestIDXUncompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	images, labels := &bytes.Buffer{}, &bytes.Buffer{}
+	binary.Write(images, binary.BigEndian, []uint32{idxImages, 1, 1, 1})
+	images.WriteByte(9)
+	binary.Write(labels, binary.BigEndian, []uint32{idxImages, 1})
+	labels.WriteByte(3)
+
+	imagesFile, labelsFile := path.Join(dir, "images"), path.Join(dir, "labels")
+	ioutil.WriteFile(imagesFile, images.Bytes(), 0644)
+	ioutil.WriteFile(labelsFile, labels.Bytes(), 0644)
+	if _, err := OpenIDX(imagesFile, labelsFile); err == nil || !strings.HasPrefix(err.Error(), "Not an IDX file of labels") {
+		t.Errorf("Expected wrong magic number to be reported, got %v", err)
+	}
+
+	content := labels.Bytes()
+	binary.BigEndian.PutUint32(content, idxLabels)
+	ioutil.WriteFile(labelsFile, content, 0644)
+	r, err := OpenIDX(imagesFile, labelsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	pic := make([]uint8, 1)
+	if label, err := r.Read(pic); err != nil || label != 3 || pic[0] != 9 {
+		t.Errorf("Unexpected image %v with label %v (%v)", pic, label, err)
+	}
+}
+
+func TestLabelMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "labels.txt")
+	if err := WriteLabelMap(fileName, []string{"0", "A", "blank"}); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := ReadLabelMap(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(labels, ",") != "0,A,blank" {
+		t.Errorf("Expected labels to be read back, got %v", labels)
+	}
+}
+
+func TestImportIDXSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 2 rows of 3 columns
+	images, labels := &bytes.Buffer{}, &bytes.Buffer{}
+	binary.Write(images, binary.BigEndian, []uint32{idxImages, 1, 2, 3})
+	images.Write(make([]byte, 6))
+	binary.Write(labels, binary.BigEndian, []uint32{idxLabels, 1})
+	labels.WriteByte(0)
+	imagesFile, labelsFile := path.Join(dir, "images"), path.Join(dir, "labels")
+	ioutil.WriteFile(imagesFile, images.Bytes(), 0644)
+	ioutil.WriteFile(labelsFile, labels.Bytes(), 0644)
+
+	fileName := path.Join(dir, "data.dat")
+	record := func(pic []uint8, label int) interface{} { return testRecord{} }
+	err = ImportIDX(imagesFile, labelsFile, fileName, 1, Header{Width: 2, Height: 3}, false, record)
+	if err == nil || err.Error() != "IDX files hold 3x2 images, expected 2x3" {
+		t.Errorf("Expected size mismatch to be reported, got %v", err)
+	}
+	if err := ImportIDX(imagesFile, labelsFile, fileName, 1, Header{Width: 3, Height: 2}, false, record); err != nil {
+		t.Errorf("Expected 3 pixels wide and 2 pixels high images to be imported, got %v", err)
+	}
+}
+
+func TestExportIDXFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "data.dat")
+	writeRecords(t, fileName, Header{Kind: KindGrid, Width: 2, Height: 2, Labels: []string{"a"}}, 2)
+
+	imagesFile, labelsFile := path.Join(dir, "images.gz"), path.Join(dir, "labels.gz")
+	decode := func(r *Reader) ([]uint8, int, error) {
+		record := testRecord{}
+		if err := r.Decode(&record); err != nil {
+			return nil, 0, err
+		}
+		// Label out of IDX range
+		return record.Pic[:], 256, nil
+	}
+	if _, err := ExportIDX(fileName, KindGrid, 2, imagesFile, labelsFile, decode); err != ErrIDXLabel {
+		t.Errorf("Expected %v, got %v", ErrIDXLabel, err)
+	}
+	for _, name := range []string{imagesFile, labelsFile} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("Expected partial %v to be removed, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package dataset
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// outDirMarker is a file put in every output directory created by generators
+const outDirMarker = ".generated"
+
+var ErrOutDirNotEmpty = errors.New("Output directory is not empty and was not created by generator, use --force to overwrite it")
+
+// PrepareOutDir makes empty output directory for generated data. Existing directory is removed only
+// when it is empty, was created by PrepareOutDir before or force is set.
+func PrepareOutDir(dir string, force bool) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(files) > 0 && !force {
+		if _, err := os.Stat(path.Join(dir, outDirMarker)); os.IsNotExist(err) {
+			return ErrOutDirNotEmpty
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0764); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, outDirMarker), nil, 0644)
+}
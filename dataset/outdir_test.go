@@ -0,0 +1,49 @@
+package dataset
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPrepareOutDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	userFile := path.Join(dir, "notes.txt")
+	if err := ioutil.WriteFile(userFile, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PrepareOutDir(dir, false); err != ErrOutDirNotEmpty {
+		t.Fatalf("Expected %v, got %v", ErrOutDirNotEmpty, err)
+	}
+	if _, err := os.Stat(userFile); err != nil {
+		t.Errorf("Expected file to be kept, got %v", err)
+	}
+
+	if err := PrepareOutDir(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(userFile); !os.IsNotExist(err) {
+		t.Errorf("Expected file to be removed when forced, got %v", err)
+	}
+
+	// Directory created by generator can be cleaned without force
+	ioutil.WriteFile(path.Join(dir, "train.dat"), []byte("data"), 0644)
+	if err := PrepareOutDir(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected only marker file to be left, got %v files", len(files))
+	}
+
+	missing := path.Join(dir, "new", "out")
+	if err := PrepareOutDir(missing, false); err != nil {
+		t.Errorf("Expected missing directory to be created, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package dataset
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReadFileHeader reads header of data file
+func ReadFileHeader(fileName string) (Header, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return Header{}, err
+	}
+	defer file.Close()
+
+	header, _, err := ReadHeader(file)
+	return header, err
+}
+
+// Print writes human readable description of header, leaving out what file does not store
+func (h Header) Print(w io.Writer) {
+	fmt.Fprintln(w, "Version:", h.Version)
+	if h.Kind != "" {
+		fmt.Fprintln(w, "Kind:", h.Kind)
+		fmt.Fprintf(w, "Images: %dx%d\n", h.Width, h.Height)
+	}
+	if h.Count != CountUnknown {
+		fmt.Fprintln(w, "Records:", h.Count)
+	}
+	if h.Labels != nil {
+		fmt.Fprintln(w, "Labels:", strings.Join(h.Labels, " "))
+	}
+	names := make([]string, 0, len(h.Params))
+	for name := range h.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, h.Params[name])
+	}
+}
+
+// Reader decodes records of data file, checking them against its header
+type Reader struct {
+	Header Header
+	dec    *gob.Decoder
+	read   int
+	closer io.Closer
+}
+
+// NewReader reads header of data file expected to hold records of kind with images of size x size pixels
+func NewReader(r io.Reader, kind string, size int) (*Reader, error) {
+	header, r, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := header.Check(kind, size); err != nil {
+		return nil, err
+	}
+	return &Reader{Header: header, dec: gob.NewDecoder(r)}, nil
+}
+
+// Open opens data file expected to hold records of kind with images of size x size pixels
+func Open(fileName, kind string, size int) (*Reader, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(file, kind, size)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %v", fileName, err)
+	}
+	r.closer = file
+	return r, nil
+}
+
+// Decode decodes next record into v. Returns io.EOF after the last record,
+// or error if number of records in file differs from one given in header.
+func (r *Reader) Decode(v interface{}) error {
+	err := r.dec.Decode(v)
+	counted := r.Header.Count != CountUnknown
+	if err == io.EOF && counted && r.read != r.Header.Count {
+		return fmt.Errorf("Data file holds %d records, expected %d", r.read, r.Header.Count)
+	} else if err != nil {
+		return err
+	}
+	if counted && r.read == r.Header.Count {
+		return fmt.Errorf("Data file holds more than %d records", r.Header.Count)
+	}
+	r.read++
+	return nil
+}
+
+// Close closes file opened by Open
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Writer encodes records following header, counting them
+type Writer struct {
+	w     io.Writer
+	enc   *gob.Encoder
+	count int
+}
+
+// NewWriter writes header of current version, its number of records is stored by Close
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.Count = CountUnknown
+	if err := WriteHeader(w, header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, enc: gob.NewEncoder(w)}, nil
+}
+
+// Encode writes record
+func (w *Writer) Encode(v interface{}) error {
+	if err := w.enc.Encode(v); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Close stores number of written records in header. Count stays unknown
+// when underlying writer can not write at given position, unlike files.
+func (w *Writer) Close() error {
+	if at, ok := w.w.(io.WriterAt); ok {
+		return SetCount(at, w.count)
+	}
+	return nil
+}
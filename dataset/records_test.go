@@ -0,0 +1,133 @@
+package dataset
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+type testRecord struct {
+	Pic [4]uint8
+}
+
+func writeRecords(t *testing.T, fileName string, header Header, count int) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w, err := NewWriter(file, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := w.Encode(testRecord{Pic: [4]uint8{uint8(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "data.dat")
+	writeRecords(t, fileName, Header{Kind: KindGrid, Width: 2, Height: 2}, 3)
+
+	if _, err := Open(fileName, KindDigit, 2); err == nil {
+		t.Error("Expected records of other kind to be rejected")
+	}
+
+	r, err := Open(fileName, KindGrid, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	header, err := ReadFileHeader(fileName)
+	if err != nil || header.Count != 3 || header.Kind != KindGrid {
+		t.Errorf("Unexpected header %+v (%v)", header, err)
+	}
+	if r.Header.Count != 3 {
+		t.Errorf("Expected count of 3 records in header, got %v", r.Header.Count)
+	}
+	for i := 0; i < 3; i++ {
+		record := testRecord{}
+		if err := r.Decode(&record); err != nil || record.Pic[0] != uint8(i) {
+			t.Errorf("Expected record %v, got %v (%v)", i, record, err)
+		}
+	}
+	if err := r.Decode(&testRecord{}); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestReaderMissingRecords(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, Header{Kind: KindGrid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Encode(testRecord{})
+	// Count is stored at its position by hand, as buffer can not be written at
+	content := buf.Bytes()
+	copy(content[countOffset:], []byte{0, 0, 0, 0, 0, 0, 0, 2})
+
+	r, err := NewReader(bytes.NewReader(content), KindGrid, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Decode(&testRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Decode(&testRecord{}); err == nil || err.Error() != "Data file holds 1 records, expected 2" {
+		t.Errorf("Expected missing record to be reported, got %v", err)
+	}
+}
+
+func TestReaderExtraRecords(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, Header{Kind: KindGrid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Encode(testRecord{})
+	w.Encode(testRecord{})
+	content := buf.Bytes()
+	copy(content[countOffset:], []byte{0, 0, 0, 0, 0, 0, 0, 1})
+
+	r, err := NewReader(bytes.NewReader(content), KindGrid, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Decode(&testRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Decode(&testRecord{}); err == nil || err.Error() != "Data file holds more than 1 records" {
+		t.Errorf("Expected extra record to be reported, got %v", err)
+	}
+}
+
+func TestHeaderPrint(t *testing.T) {
+	buf := &bytes.Buffer{}
+	Header{Version: Version, Count: 2, Kind: KindDigit, Width: 28, Height: 28, Labels: []string{"0", "1"}, Params: map[string]string{"seed": "7", "blanks": "0"}}.Print(buf)
+	expected := "Version: 3\nKind: digit\nImages: 28x28\nRecords: 2\nLabels: 0 1\nblanks: 0\nseed: 7\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	Header{Version: VersionLegacy, Count: CountUnknown}.Print(buf)
+	if buf.String() != "Version: 0\n" {
+		t.Errorf("Expected only version of legacy file, got %q", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package dataset
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"sort"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const sheetPadding = 4
+
+var sheetBackground = color.Gray{Y: 64}
+
+// SheetRow is a row of contact sheet: label followed by pictures
+type SheetRow struct {
+	Label string
+	Pics  [][]uint8
+}
+
+// Sampler picks up to n random pictures per label from stream of pictures of unknown length
+type Sampler struct {
+	n     int
+	rng   *rand.Rand
+	seen  map[string]int
+	picks map[string][][]uint8
+}
+
+func NewSampler(n int, rng *rand.Rand) *Sampler {
+	return &Sampler{
+		n:     n,
+		rng:   rng,
+		seen:  map[string]int{},
+		picks: map[string][][]uint8{},
+	}
+}
+
+// Add offers picture with given label. Every picture of label has the same chance to be picked.
+func (s *Sampler) Add(label string, pic []uint8) {
+	s.seen[label]++
+	picks := s.picks[label]
+
+	pos := len(picks)
+	if pos >= s.n {
+		pos = s.rng.Intn(s.seen[label])
+		if pos >= s.n {
+			return
+		}
+	}
+
+	cp := make([]uint8, len(pic))
+	copy(cp, pic)
+	if pos == len(picks) {
+		s.picks[label] = append(picks, cp)
+	} else {
+		picks[pos] = cp
+	}
+}
+
+// Rows returns picked pictures, row per label sorted by label
+func (s *Sampler) Rows() []SheetRow {
+	rows := make([]SheetRow, 0, len(s.picks))
+	for label, pics := range s.picks {
+		rows = append(rows, SheetRow{Label: label, Pics: pics})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Label < rows[j].Label
+	})
+	return rows
+}
+
+// ContactSheet renders rows of square pictures of given size into one image, each row annotated with its label
+func ContactSheet(rows []SheetRow, size int) *image.RGBA {
+	face := basicfont.Face7x13
+	labelWidth, columns := 0, 0
+	for _, row := range rows {
+		if width := font.MeasureString(face, row.Label).Ceil(); width > labelWidth {
+			labelWidth = width
+		}
+		if len(row.Pics) > columns {
+			columns = len(row.Pics)
+		}
+	}
+
+	rowHeight := size
+	if face.Height > rowHeight {
+		rowHeight = face.Height
+	}
+	cellsLeft := labelWidth + 2*sheetPadding
+	width := cellsLeft + columns*(size+sheetPadding)
+	height := sheetPadding + len(rows)*(rowHeight+sheetPadding)
+
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(sheetBackground), image.ZP, draw.Src)
+
+	drawer := font.Drawer{Dst: sheet, Src: image.White, Face: face}
+	for i, row := range rows {
+		top := sheetPadding + i*(rowHeight+sheetPadding)
+
+		drawer.Dot = fixed.P(sheetPadding, top+(rowHeight+face.Ascent-face.Descent)/2)
+		drawer.DrawString(row.Label)
+
+		for j, pic := range row.Pics {
+			left := cellsLeft + j*(size+sheetPadding)
+			cell := image.Rect(left, top, left+size, top+size)
+			draw.Draw(sheet, cell, PicToImage(pic, size), image.ZP, draw.Src)
+		}
+	}
+	return sheet
+}
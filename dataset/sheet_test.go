@@ -0,0 +1,56 @@
+package dataset
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	sampler := NewSampler(2, rand.New(rand.NewSource(1)))
+	for i := 0; i < 10; i++ {
+		sampler.Add("b", []uint8{uint8(i)})
+	}
+	sampler.Add("a", []uint8{42})
+
+	rows := sampler.Rows()
+	if len(rows) != 2 || rows[0].Label != "a" || rows[1].Label != "b" {
+		t.Fatalf("Expected rows a and b, got %v", rows)
+	}
+	if len(rows[0].Pics) != 1 || rows[0].Pics[0][0] != 42 {
+		t.Errorf("Expected single picture of a, got %v", rows[0].Pics)
+	}
+	if len(rows[1].Pics) != 2 {
+		t.Errorf("Expected 2 pictures of b, got %v", rows[1].Pics)
+	}
+}
+
+func TestContactSheet(t *testing.T) {
+	rows := []SheetRow{
+		{Label: "one", Pics: [][]uint8{{255, 255, 255, 255}}},
+		{Label: "three", Pics: [][]uint8{{0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}}},
+	}
+	sheet := ContactSheet(rows, 2)
+
+	// Label of 5 characters, 3 pictures, rows as high as the font
+	cellsLeft := 5*7 + 2*sheetPadding
+	width := cellsLeft + 3*(2+sheetPadding)
+	height := sheetPadding + 2*(13+sheetPadding)
+	if sheet.Bounds() != image.Rect(0, 0, width, height) {
+		t.Fatalf("Expected sheet %vx%v, got %v", width, height, sheet.Bounds())
+	}
+
+	gray := func(x, y int) uint8 {
+		return color.GrayModel.Convert(sheet.At(x, y)).(color.Gray).Y
+	}
+	if gray(cellsLeft, sheetPadding) != 255 {
+		t.Errorf("Expected white picture in first row, got %v", gray(cellsLeft, sheetPadding))
+	}
+	if gray(cellsLeft+2*(2+sheetPadding), 2*sheetPadding+13) != 0 {
+		t.Error("Expected black picture at the end of second row")
+	}
+	if gray(width-1, height-1) != sheetBackground.Y {
+		t.Error("Expected background in the corner")
+	}
+}
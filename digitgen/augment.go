@@ -0,0 +1,85 @@
+package digitgen
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/mrfuxi/digit/common"
+)
+
+// elasticSigma is smoothness of elastic distortion field in pixels
+const elasticSigma = 4
+
+// Augmentation describes random distortions applied to drawn digits,
+// so they look less like clean renders of fonts
+type Augmentation struct {
+	Rotation common.AugmentRange // Degrees
+	Shear    common.AugmentRange // Horizontal shift per pixel of height
+	Elastic  common.AugmentRange // Longest move of pixel
+	Stroke   common.AugmentRange // Pixels added to (positive) or removed from (negative) stroke
+	Blur     common.AugmentRange // Standard deviation of gaussian in pixels
+	Noise    common.AugmentRange // Fraction of pixels turned black or white
+}
+
+// DefaultAugmentation returns distortions used when augmentation is turned on
+func DefaultAugmentation() Augmentation {
+	return Augmentation{
+		Rotation: common.AugmentRange{Probability: 0.5, Min: -10, Max: 10},
+		Shear:    common.AugmentRange{Probability: 0.3, Min: -0.3, Max: 0.3},
+		Elastic:  common.AugmentRange{Probability: 0.3, Min: 0.5, Max: 2},
+		Stroke:   common.AugmentRange{Probability: 0.3, Min: -1, Max: 1},
+		Blur:     common.AugmentRange{Probability: 0.3, Min: 0.3, Max: 1},
+		Noise:    common.AugmentRange{Probability: 0.2, Min: 0, Max: 0.03},
+	}
+}
+
+func (a Augmentation) String() string {
+	return fmt.Sprintf(
+		"rotation %v, shear %v, elastic %v, stroke %v, blur %v, noise %v",
+		a.Rotation, a.Shear, a.Elastic, a.Stroke, a.Blur, a.Noise,
+	)
+}
+
+// Apply distorts image. Same rng state gives the same result.
+func (a Augmentation) Apply(img image.Image, rng *rand.Rand) *image.Gray {
+	gray := common.ToGray(img)
+	center := float64(ImageSize) / 2
+
+	// Rotation and shear around center of image
+	transform := common.IdentityHomography()
+	if angle, ok := a.Rotation.Pick(rng); ok {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		transform = common.Homography{cos, -sin, 0, sin, cos, 0, 0, 0, 1}.Mul(transform)
+	}
+	if shear, ok := a.Shear.Pick(rng); ok {
+		transform = common.Homography{1, shear, 0, 0, 1, 0, 0, 0, 1}.Mul(transform)
+	}
+	if transform != common.IdentityHomography() {
+		toCenter := common.Homography{1, 0, -center, 0, 1, -center, 0, 0, 1}
+		back := common.Homography{1, 0, center, 0, 1, center, 0, 0, 1}
+		if warped, err := common.Warp(gray, back.Mul(transform).Mul(toCenter)); err == nil {
+			gray = warped
+		}
+	}
+
+	if alpha, ok := a.Elastic.Pick(rng); ok {
+		gray = common.ElasticDistortion(gray, alpha, elasticSigma, rng)
+	}
+	if stroke, ok := a.Stroke.Pick(rng); ok {
+		radius := int(math.Floor(math.Abs(stroke) + 0.5))
+		if stroke > 0 {
+			gray = common.Dilate(gray, radius)
+		} else {
+			gray = common.Erode(gray, radius)
+		}
+	}
+	if sigma, ok := a.Blur.Pick(rng); ok {
+		gray = common.GaussianBlur(gray, sigma)
+	}
+	if fraction, ok := a.Noise.Pick(rng); ok {
+		gray = common.SaltAndPepper(gray, fraction, rng)
+	}
+	return gray
+}
@@ -0,0 +1,37 @@
+package digitgen
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/mrfuxi/digit/common"
+)
+
+func TestAugmentationApply(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, ImageSize, ImageSize))
+	for y := 6; y < 22; y++ {
+		for x := 12; x < 16; x++ {
+			img.Pix[img.PixOffset(x, y)] = 255
+		}
+	}
+
+	always := DefaultAugmentation()
+	for _, r := range []*common.AugmentRange{&always.Rotation, &always.Shear, &always.Elastic, &always.Stroke, &always.Blur, &always.Noise} {
+		r.Probability = 1
+	}
+
+	first := always.Apply(img, rand.New(rand.NewSource(7)))
+	second := always.Apply(img, rand.New(rand.NewSource(7)))
+	if string(first.Pix) != string(second.Pix) {
+		t.Error("Expected the same seed to give the same image")
+	}
+	if string(first.Pix) == string(img.Pix) {
+		t.Error("Expected image to be distorted")
+	}
+
+	never := Augmentation{}
+	if got := never.Apply(img, rand.New(rand.NewSource(7))); string(got.Pix) != string(img.Pix) {
+		t.Error("Expected image to be left untouched without distortions")
+	}
+}
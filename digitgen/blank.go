@@ -0,0 +1,66 @@
+package digitgen
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/mrfuxi/digit/common"
+)
+
+// BlankChar is label of images of empty cells, drawn without font
+const BlankChar = "blank"
+
+// DefaultBlanks is number of empty cells drawn when none is given
+const DefaultBlanks = 5000
+
+// drawBlank draws empty cell: dark paper with some noise and sometimes remnants of grid lines
+// along its edges, left after cutting the cell out of photo
+func drawBlank(rng *rand.Rand) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, ImageSize, ImageSize))
+
+	paper := rng.Intn(40)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(paper + rng.Intn(10))
+	}
+
+	// Remnants of grid lines, each edge on its own
+	for edge := 0; edge < 4; edge++ {
+		if rng.Float64() >= 0.3 {
+			continue
+		}
+		width := 1 + rng.Intn(3)
+		brightness := 100 + rng.Intn(156)
+		// Lines are rarely perfectly straight after cutting, let them drift a little
+		drift := (rng.Float64()*2 - 1) * 2
+		for pos := 0; pos < ImageSize; pos++ {
+			shift := int(math.Floor(drift*float64(pos)/ImageSize + 0.5))
+			for w := 0; w < width; w++ {
+				depth := w + shift
+				if depth < 0 || depth >= ImageSize {
+					continue
+				}
+				var x, y int
+				switch edge {
+				case 0:
+					x, y = pos, depth
+				case 1:
+					x, y = ImageSize-1-depth, pos
+				case 2:
+					x, y = pos, ImageSize-1-depth
+				default:
+					x, y = depth, pos
+				}
+				img.Pix[img.PixOffset(x, y)] = uint8(brightness)
+			}
+		}
+	}
+
+	if rng.Float64() < 0.5 {
+		img = common.SaltAndPepper(img, rng.Float64()*0.02, rng)
+	}
+	if rng.Float64() < 0.5 {
+		img = common.GaussianBlur(img, 0.3+rng.Float64()*0.7)
+	}
+	return img
+}
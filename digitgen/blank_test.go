@@ -0,0 +1,30 @@
+package digitgen
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDrawBlank(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		img := drawBlank(rand.New(rand.NewSource(seed)))
+		if img.Bounds().Dx() != ImageSize || img.Bounds().Dy() != ImageSize {
+			t.Fatalf("Expected %vx%v image, got %v", ImageSize, ImageSize, img.Bounds())
+		}
+
+		// Line remnants stay at edges, middle of cell is dark
+		for y := ImageSize / 4; y < ImageSize*3/4; y++ {
+			for x := ImageSize / 4; x < ImageSize*3/4; x++ {
+				if pix := img.GrayAt(x, y).Y; pix > 128 && pix != 255 {
+					t.Fatalf("Seed %v: expected dark middle, got %v at (%v, %v)", seed, pix, x, y)
+				}
+			}
+		}
+	}
+
+	first := drawBlank(rand.New(rand.NewSource(1)))
+	second := drawBlank(rand.New(rand.NewSource(1)))
+	if string(first.Pix) != string(second.Pix) {
+		t.Error("Expected the same seed to give the same image")
+	}
+}
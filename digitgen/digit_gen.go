@@ -1,24 +1,28 @@
 package digitgen
 
 import (
-	"encoding/gob"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"unicode"
 
 	"gopkg.in/cheggaaa/pb.v1"
 
 	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
 	"github.com/petar/GoMNIST"
 )
 
@@ -30,20 +34,99 @@ const (
 	FTypeMachine FType = 1 << iota
 	FTypeHand
 	FTypeTrueHand
+	FTypeBlank // Empty cell without any character
 )
 
+func (t FType) String() string {
+	switch t {
+	case FTypeMachine:
+		return "machine"
+	case FTypeHand:
+		return "hand"
+	case FTypeTrueHand:
+		return "true-hand"
+	case FTypeBlank:
+		return "blank"
+	}
+	return fmt.Sprintf("FType(%d)", uint8(t))
+}
+
+// Options of generating digits
+type Options struct {
+	// Print number of records per font type and character
+	Stats bool
+	// Seed of random numbers generator. Same seed gives the same data files.
+	Seed int64
+
+	// Directory for generated data, removed before generating
+	OutDir string
+	// Remove output directory even if it was not created by generator
+	Force bool
+	// Directory with "hand" and "machine" subdirectories of TTF fonts
+	FontDir string
+	// Directory with MNIST data set
+	MnistDir string
+	// Data files, by default placed in output directory
+	TrainFile string
+	TestFile  string
+	// Save images as PNG files in per label directories of output directory
+	PNG bool
+	// Save only PNG files, without data files
+	PNGOnly bool
+	// Random distortions of drawn digits, nil turns them off. MNIST digits are not distorted.
+	Augmentation *Augmentation
+	// Number of images of empty cells, labeled with BlankChar
+	Blanks int
+	// Leave out MNIST digits, e.g. when drawing letters or other numeral systems
+	NoMnist bool
+}
+
+// setDefaults fills in paths which were not given
+func (o *Options) setDefaults() {
+	if o.OutDir == "" {
+		o.OutDir = DefaultOutDir
+	}
+	if o.FontDir == "" {
+		o.FontDir = DefaultFontDir
+	}
+	if o.MnistDir == "" {
+		o.MnistDir = DefaultMnistDir
+	}
+	if o.TrainFile == "" {
+		o.TrainFile = path.Join(o.OutDir, TrainFileName)
+	}
+	if o.TestFile == "" {
+		o.TestFile = path.Join(o.OutDir, TestFileName)
+	}
+	if o.PNGOnly {
+		o.PNG = true
+	}
+}
+
 type fontMap struct {
 	name  string
 	ftype FType
 }
 
+// Font is TTF font which can be used to draw digits
+type Font struct {
+	Name string // Path relative to font directory
+	Type FType
+}
+
+const (
+	DefaultOutDir = "out_digit"
+	TestFileName  = "digit_test.dat"
+	TrainFileName = "digit_train.dat"
+	PNGDirName    = "png"
+)
+
 var (
-	outDir      = "out_digit"
-	TestFile    = path.Join(outDir, "digit_test.dat")
-	TrainFile   = path.Join(outDir, "digit_train.dat")
-	mnistDir    = path.Join("digitgen", "mnist")
-	fontDir     = path.Join("digitgen", "fonts")
-	fontSubDirs = []fontMap{
+	TestFile        = path.Join(DefaultOutDir, TestFileName)
+	TrainFile       = path.Join(DefaultOutDir, TrainFileName)
+	DefaultMnistDir = path.Join("digitgen", "mnist")
+	DefaultFontDir  = path.Join("digitgen", "fonts")
+	fontSubDirs     = []fontMap{
 		{"hand", FTypeHand},
 		{"machine", FTypeMachine},
 	}
@@ -61,6 +144,8 @@ type CharInfo struct {
 
 type DrawDirections struct {
 	CharInfo
+	Seq      int   // Position of image in output
+	Seed     int64 // Seed of random distortions
 	FontName string
 	FontSize float64
 	Dx       float64
@@ -69,7 +154,9 @@ type DrawDirections struct {
 
 type Image struct {
 	CharInfo
-	Image image.Image
+	Seq   int
+	Seed  int64
+	Image image.Image // Nil when image could not be drawn
 }
 
 type Counter struct {
@@ -78,6 +165,7 @@ type Counter struct {
 }
 
 type Record struct {
+	// Grayscale pixels in row-major layout, see dataset package
 	Pic  [ImageSize * ImageSize]uint8
 	Char string
 	Type FType
@@ -104,6 +192,11 @@ func verifyFont(fontName string) error {
 }
 
 func drawDigit(directions DrawDirections) (img image.Image, err error) {
+	if directions.Char == BlankChar {
+		// Seed is shared with augmentation, so blank uses generator of its own
+		return drawBlank(rand.New(rand.NewSource(^directions.Seed))), nil
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			var ok bool
@@ -156,29 +249,38 @@ func draw(directions <-chan DrawDirections, images chan<- Image) {
 		if err != nil {
 			progress.Increment()
 			// fmt.Println(direction.FontName, direction.Char, direction.FontSize, err)
-			continue
 		}
 		images <- Image{
 			CharInfo: direction.CharInfo,
+			Seq:      direction.Seq,
+			Seed:     direction.Seed,
 			Image:    digit,
 		}
 	}
 }
 
-func prepareDrawDirections(text string, directions chan<- DrawDirections) {
-	fontSizes := []float64{14, 16, 18, 20, 22, 24, 26}
-	movements := []float64{-4, 0, 4}
+// augment distorts drawn images. Each image has its own random numbers generator,
+// so result does not depend on which routine distorted it.
+func augment(augmentation Augmentation, drawn <-chan Image, augmented chan<- Image) {
+	for img := range drawn {
+		if img.Image != nil {
+			img.Image = augmentation.Apply(img.Image, rand.New(rand.NewSource(img.Seed)))
+		}
+		augmented <- img
+	}
+}
 
+// LoadFonts makes draw2d use fonts from fontDir and returns all fonts which can be drawn
+func LoadFonts(fontDir string) ([]Font, error) {
 	draw2d.SetFontFolder(fontDir)
 	draw2d.SetFontNamer(fontFileName)
 
-	var fonts []fontMap
+	var fonts []Font
 	for _, fontSubDir := range fontSubDirs {
 		fontSubDirPath := path.Join(fontDir, fontSubDir.name)
 		fontFiles, err := ioutil.ReadDir(fontSubDirPath)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return nil, err
 		}
 
 		for _, font := range fontFiles {
@@ -189,13 +291,25 @@ func prepareDrawDirections(text string, directions chan<- DrawDirections) {
 				fmt.Println(err, fontPath)
 				continue
 			}
-			fonts = append(fonts, fontMap{fontPath, fontSubDir.ftype})
+			fonts = append(fonts, Font{fontPath, fontSubDir.ftype})
 		}
 	}
+	return fonts, nil
+}
+
+// prepareDrawDirections directs drawing of each char of text with all fonts, followed by empty cells
+func prepareDrawDirections(text string, fonts []Font, options Options, rng *rand.Rand, directions chan<- DrawDirections) {
+	fontSizes := []float64{14, 16, 18, 20, 22, 24, 26}
+	movements := []float64{-4, 0, 4}
 
 	mnistSize := 60000 + 10000
-	progress = pb.StartNew(len(fonts)*len(text)*len(fontSizes)*len(movements)*len(movements) + mnistSize)
+	if options.NoMnist {
+		mnistSize = 0
+	}
+	blanks := options.Blanks
+	progress = pb.StartNew(len(fonts)*len([]rune(text))*len(fontSizes)*len(movements)*len(movements) + blanks + mnistSize)
 
+	seq := 0
 	for _, font := range fonts {
 		for _, c := range text {
 			for _, fontSize := range fontSizes {
@@ -204,43 +318,174 @@ func prepareDrawDirections(text string, directions chan<- DrawDirections) {
 						directions <- DrawDirections{
 							CharInfo: CharInfo{
 								Char:  string(c),
-								Type:  font.ftype,
-								Train: rand.Intn(100) >= 5,
+								Type:  font.Type,
+								Train: rng.Intn(100) >= 5,
 							},
-							FontName: font.name,
+							Seq:      seq,
+							Seed:     rng.Int63(),
+							FontName: font.Name,
 							FontSize: fontSize,
 							Dx:       dx,
 							Dy:       dy,
 						}
+						seq++
 					}
 				}
 			}
 		}
 	}
+
+	for i := 0; i < blanks; i++ {
+		directions <- DrawDirections{
+			CharInfo: CharInfo{
+				Char:  BlankChar,
+				Type:  FTypeBlank,
+				Train: rng.Intn(100) >= 5,
+			},
+			Seq:  seq,
+			Seed: rng.Int63(),
+		}
+		seq++
+	}
 }
 
-func imgCouter(images <-chan Image, counters chan<- Counter) {
+// imgCouter numbers images and passes them to all savers
+func imgCouter(images <-chan Image, stats *Stats, savers ...chan<- Counter) {
 	cnt := 1
 	for img := range images {
-		counters <- Counter{
+		counter := Counter{
 			Image: img,
 			ID:    cnt,
 		}
+		for _, saver := range savers {
+			saver <- counter
+		}
+		stats.Add(newRecord(counter), counter.CharInfo.Train)
+		progress.Increment()
 		cnt++
 	}
 }
 
-func imgSaver(counters <-chan Counter) {
+// imgSaver writes images as PNG files, each label in its own directory
+func imgSaver(outDir string, counters <-chan Counter) {
 	for counter := range counters {
-		fileName := fmt.Sprintf("char-%06d-%v.png", counter.ID, counter.Char)
-		if err := draw2dimg.SaveToPngFile(path.Join(outDir, fileName), counter.Image.Image); err != nil {
+		label := labelName(counter.Char)
+		labelDir := path.Join(outDir, label)
+		if err := os.MkdirAll(labelDir, 0764); err != nil {
 			fmt.Println(err)
+			continue
 		}
-		progress.Increment()
+
+		fileName := fmt.Sprintf("char-%06d-%v.png", counter.ID, label)
+		if err := draw2dimg.SaveToPngFile(path.Join(labelDir, fileName), counter.Image.Image); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// labelName returns label safe to use in file names. Labels with chars other than letters
+// and digits (e.g. "/" or "..") are written as their code points, like "U+002F".
+func labelName(label string) string {
+	safe := label != ""
+	for _, c := range label {
+		safe = safe && (unicode.IsLetter(c) || unicode.IsDigit(c))
+	}
+	if safe {
+		return label
+	}
+
+	codes := make([]string, 0, len(label))
+	for _, c := range label {
+		codes = append(codes, fmt.Sprintf("U+%04X", c))
+	}
+	return strings.Join(codes, "_")
+}
+
+func newRecord(counter Counter) Record {
+	return Record{
+		Pic:  ImageToPic(counter.Image.Image),
+		Char: counter.CharInfo.Char,
+		Type: counter.CharInfo.Type,
+	}
+}
+
+// labelMap returns labels of generated data: chars of text in order of their first appearance,
+// followed by MNIST digits missing in text and BlankChar
+func labelMap(text string, options Options) []string {
+	var labels []string
+	seen := map[string]bool{}
+	add := func(label string) {
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+
+	for _, c := range text {
+		add(string(c))
+	}
+	if !options.NoMnist {
+		for digit := 0; digit <= 9; digit++ {
+			add(strconv.Itoa(digit))
+		}
+	}
+	if options.Blanks > 0 {
+		add(BlankChar)
+	}
+	return labels
+}
+
+// sortLabels orders labels of data file written without label map: digits first, other chars
+// alphabetically and BlankChar last
+func sortLabels(chars map[string]bool) []string {
+	labels := make([]string, 0, len(chars))
+	for char := range chars {
+		labels = append(labels, char)
+	}
+	rank := func(label string) int {
+		switch {
+		case label == BlankChar:
+			return 2
+		case len(label) == 1 && label[0] >= '0' && label[0] <= '9':
+			return 0
+		}
+		return 1
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if rank(labels[i]) != rank(labels[j]) {
+			return rank(labels[i]) < rank(labels[j])
+		}
+		return labels[i] < labels[j]
+	})
+	return labels
+}
+
+// header describes data files of chars with labels generated with params
+func header(labels []string, params map[string]string) dataset.Header {
+	return dataset.Header{
+		Kind:   dataset.KindDigit,
+		Width:  ImageSize,
+		Height: ImageSize,
+		Labels: labels,
+		Params: params,
 	}
 }
 
-func gobSaver(trainFile string, testFile string, counters <-chan Counter) {
+// params returns text and options chars were generated with, stored in header of data files
+func (o Options) params(text string) map[string]string {
+	params := map[string]string{
+		"text":   text,
+		"seed":   fmt.Sprint(o.Seed),
+		"blanks": fmt.Sprint(o.Blanks),
+		"mnist":  fmt.Sprint(!o.NoMnist),
+	}
+	if o.Augmentation != nil {
+		params["augmentation"] = fmt.Sprintf("%+v", *o.Augmentation)
+	}
+	return params
+}
+
+func gobSaver(trainFile string, testFile string, header dataset.Header, counters <-chan Counter) {
 	csvFileTrain, err := os.Create(trainFile)
 	if err != nil {
 		panic(err)
@@ -253,35 +498,43 @@ func gobSaver(trainFile string, testFile string, counters <-chan Counter) {
 	}
 	defer csvFileTest.Close()
 
-	train := gob.NewEncoder(csvFileTrain)
-	test := gob.NewEncoder(csvFileTest)
+	train, err := dataset.NewWriter(csvFileTrain, header)
+	if err != nil {
+		panic(err)
+	}
+	test, err := dataset.NewWriter(csvFileTest, header)
+	if err != nil {
+		panic(err)
+	}
 
 	for counter := range counters {
-		bounds := counter.Image.Image.Bounds()
-		record := Record{
-			Char: counter.CharInfo.Char,
-			Type: counter.CharInfo.Type,
-		}
+		record := newRecord(counter)
 
-		pos := 0
-		for x := 0; x < bounds.Max.X; x++ {
-			for y := 0; y < bounds.Max.Y; y++ {
-				clr := counter.Image.Image.At(x, y)
-				grayColor := color.GrayModel.Convert(clr).(color.Gray)
-				record.Pic[pos] = grayColor.Y
-				pos++
-			}
-		}
 		if counter.CharInfo.Train {
-			train.Encode(record)
+			err = train.Encode(record)
 		} else {
-			test.Encode(record)
+			err = test.Encode(record)
 		}
-		progress.Increment()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := train.Close(); err != nil {
+		panic(err)
+	}
+	if err := test.Close(); err != nil {
+		panic(err)
 	}
 }
 
-func drawMnist(images chan<- Image) {
+// ImageToPic converts image to pixels in the same layout as stored in Record
+func ImageToPic(img image.Image) (pic [ImageSize * ImageSize]uint8) {
+	dataset.ImageToPic(img, pic[:], ImageSize)
+	return
+}
+
+func drawMnist(mnistDir string, images chan<- Image) {
 	train, test, err := GoMNIST.Load(mnistDir)
 	if err != nil {
 		panic(err)
@@ -299,7 +552,7 @@ func drawMnist(images chan<- Image) {
 		}
 	}
 	for i := 0; i < test.Count(); i++ {
-		img, label := train.Get(i)
+		img, label := test.Get(i)
 		images <- Image{
 			CharInfo: CharInfo{
 				Char:  strconv.Itoa(int(label)),
@@ -311,34 +564,167 @@ func drawMnist(images chan<- Image) {
 	}
 }
 
-func GeneratDigits(text string) error {
+func GeneratDigits(text string, options Options) error {
 	if text == "" {
 		fmt.Println(ErrNoText)
 		return ErrNoText
 	}
 
-	os.RemoveAll(outDir)
-	if err := os.Mkdir(outDir, 0764); err != nil {
+	options.setDefaults()
+	if err := dataset.PrepareOutDir(options.OutDir, options.Force); err != nil {
+		return err
+	}
+
+	fonts, err := LoadFonts(options.FontDir)
+	if err != nil {
 		return err
 	}
 
+	rng := rand.New(rand.NewSource(options.Seed))
+
 	directions := make(chan DrawDirections, 100)
+	drawn := make(chan Image, 100)
 	images := make(chan Image, 100)
 	counters := make(chan Counter, 100)
+	pngCounters := make(chan Counter, 100)
+
+	var savers []chan<- Counter
+	wgSavers := sync.WaitGroup{}
+	if !options.PNGOnly {
+		savers = append(savers, counters)
+		wgSavers.Add(1)
+		common.RoutineRunner(1, true, func() {
+			gobSaver(options.TrainFile, options.TestFile, header(labelMap(text, options), options.params(text)), counters)
+		}, wgSavers.Done)
+	}
+	if options.PNG {
+		savers = append(savers, pngCounters)
+		wgSavers.Add(1)
+		common.RoutineRunner(4, true, func() { imgSaver(path.Join(options.OutDir, PNGDirName), pngCounters) }, wgSavers.Done)
+	}
 
-	wgProducer := sync.WaitGroup{}
-	wgProducer.Add(2)
-	go func() {
-		wgProducer.Wait()
-		close(images)
-	}()
+	// MNIST goes after all drawn images, so their order is always the same
+	stats := NewStats()
+	common.RoutineRunner(1, true, func() { prepareDrawDirections(text, fonts, options, rng, directions) }, func() { close(directions) })
+	common.RoutineRunner(4, true, func() { draw(directions, drawn) }, func() { close(drawn) })
+	ready := drawn
+	if options.Augmentation != nil {
+		augmented := make(chan Image, 100)
+		common.RoutineRunner(4, true, func() { augment(*options.Augmentation, drawn, augmented) }, func() { close(augmented) })
+		ready = augmented
+	}
+	common.RoutineRunner(1, true, func() {
+		// Images which could not be drawn are dropped
+		common.Reorder(func() (int, interface{}, bool) {
+			img, ok := <-ready
+			return img.Seq, img, ok
+		}, func(item interface{}) {
+			if img := item.(Image); img.Image != nil {
+				images <- img
+			}
+		})
+		if !options.NoMnist {
+			drawMnist(options.MnistDir, images)
+		}
+	}, func() { close(images) })
+	common.RoutineRunner(1, true, func() { imgCouter(images, stats, savers...) }, func() {
+		close(counters)
+		close(pngCounters)
+	})
+	wgSavers.Wait()
+	progress.Finish()
+
+	if options.Stats {
+		stats.Print(os.Stdout)
+	}
+	return nil
+}
 
-	common.RoutineRunner(1, true, func() { prepareDrawDirections(text, directions) }, func() { close(directions) })
-	common.RoutineRunner(4, true, func() { draw(directions, images) }, func() { wgProducer.Done() })
-	common.RoutineRunner(1, true, func() { drawMnist(images) }, func() { wgProducer.Done() })
-	common.RoutineRunner(1, true, func() { imgCouter(images, counters) }, func() { close(counters) })
-	// common.RoutineRunner(4, false, func() { imgSaver(counters) }, nil)
-	common.RoutineRunner(1, false, func() { gobSaver(TrainFile, TestFile, counters) }, nil)
+// Migrate converts data file written by older version of generator to current version.
+// Label map of files written without it is made of all chars found in file, see sortLabels.
+// Records are streamed, so files with no label map are read twice.
+func Migrate(fileName string) error {
+	labels, err := migrateLabels(fileName)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return dataset.Rewrite(fileName, func(r io.Reader, w io.Writer) error {
+		in, err := dataset.NewReader(r, dataset.KindDigit, ImageSize)
+		if err != nil {
+			return err
+		}
+		out, err := dataset.NewWriter(w, header(labels, nil))
+		if err != nil {
+			return err
+		}
+		for {
+			record := Record{}
+			err := in.Decode(&record)
+			if err == io.EOF {
+				return out.Close()
+			} else if err != nil {
+				return err
+			}
+
+			dataset.FixLayout(in.Header.Version, record.Pic[:], ImageSize)
+			if err := out.Encode(record); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// migrateLabels returns label map of data file to be migrated, collecting chars of all records
+// when file has none. Returns dataset.ErrUpToDate for file in current version.
+func migrateLabels(fileName string) ([]string, error) {
+	in, err := dataset.Open(fileName, dataset.KindDigit, ImageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	if in.Header.Version == dataset.Version {
+		return nil, dataset.ErrUpToDate
+	}
+	if in.Header.Labels != nil {
+		return in.Header.Labels, nil
+	}
+
+	chars := map[string]bool{}
+	for {
+		record := Record{}
+		err := in.Decode(&record)
+		if err == io.EOF {
+			return sortLabels(chars), nil
+		} else if err != nil {
+			return nil, err
+		}
+		chars[record.Char] = true
+	}
+}
+
+// Preview renders contact sheet of data file with row of up to samples random images per font type and character
+func Preview(fileName string, samples int, rng *rand.Rand) (image.Image, error) {
+	records, err := dataset.Open(fileName, dataset.KindDigit, ImageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+
+	sampler := dataset.NewSampler(samples, rng)
+	for {
+		record := Record{}
+		err := records.Decode(&record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		dataset.FixLayout(records.Header.Version, record.Pic[:], ImageSize)
+		sampler.Add(fmt.Sprintf("%v %v", record.Type, record.Char), record.Pic[:])
+	}
+
+	return dataset.ContactSheet(sampler.Rows(), ImageSize), nil
 }
@@ -0,0 +1,144 @@
+package digitgen
+
+import (
+	"encoding/gob"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/mrfuxi/digit/dataset"
+)
+
+func TestImgSaver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digitgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pic := image.NewGray(image.Rect(0, 0, ImageSize, ImageSize))
+	counters := make(chan Counter, 4)
+	counters <- Counter{Image: Image{CharInfo: CharInfo{Char: "7"}, Image: pic}, ID: 1}
+	counters <- Counter{Image: Image{CharInfo: CharInfo{Char: "3"}, Image: pic}, ID: 2}
+	counters <- Counter{Image: Image{CharInfo: CharInfo{Char: "/"}, Image: pic}, ID: 3}
+	counters <- Counter{Image: Image{CharInfo: CharInfo{Char: ".."}, Image: pic}, ID: 4}
+	close(counters)
+
+	imgSaver(dir, counters)
+
+	for _, fileName := range []string{
+		"7/char-000001-7.png",
+		"3/char-000002-3.png",
+		"U+002F/char-000003-U+002F.png",
+		"U+002E_U+002E/char-000004-U+002E_U+002E.png",
+	} {
+		if _, err := os.Stat(path.Join(dir, fileName)); err != nil {
+			t.Errorf("Expected %v to be saved, got %v", fileName, err)
+		}
+	}
+}
+
+func TestLabelMap(t *testing.T) {
+	testCases := []struct {
+		text     string
+		options  Options
+		expected string
+	}{
+		{"0123456789", Options{Blanks: 10}, "0 1 2 3 4 5 6 7 8 9 blank"},
+		{"ABA", Options{NoMnist: true}, "A B"},
+		{"FA5", Options{}, "F A 5 0 1 2 3 4 6 7 8 9"},
+		{"१२", Options{NoMnist: true, Blanks: 1}, "१ २ blank"},
+	}
+
+	for _, tc := range testCases {
+		if got := strings.Join(labelMap(tc.text, tc.options), " "); got != tc.expected {
+			t.Errorf("labelMap(%q): expected %q, got %q", tc.text, tc.expected, got)
+		}
+	}
+}
+
+func TestSortLabels(t *testing.T) {
+	chars := map[string]bool{"blank": true, "B": true, "7": true, "A": true, "0": true}
+	if got := strings.Join(sortLabels(chars), " "); got != "0 7 A B blank" {
+		t.Errorf("Expected digits, letters and blank, got %q", got)
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	testCases := []struct {
+		label    string
+		expected string
+	}{
+		{"7", "7"},
+		{"ą", "ą"},
+		{BlankChar, BlankChar},
+		{".", "U+002E"},
+		{"..", "U+002E_U+002E"},
+		{"/", "U+002F"},
+		{"a/b", "U+0061_U+002F_U+0062"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := labelName(tc.label); got != tc.expected {
+			t.Errorf("labelName(%q): expected %q, got %q", tc.label, tc.expected, got)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digitgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Legacy file: no header and pixels stored column by column
+	fileName := path.Join(dir, "train.dat")
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := gob.NewEncoder(file)
+	for _, char := range []string{BlankChar, "A", "7"} {
+		record := Record{Char: char, Type: FTypeMachine}
+		record.Pic[1] = 200
+		if err := enc.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	if err := Migrate(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := dataset.Open(fileName, dataset.KindDigit, ImageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer records.Close()
+	if records.Header.Count != 3 || strings.Join(records.Header.Labels, " ") != "7 A "+BlankChar {
+		t.Errorf("Unexpected header %+v", records.Header)
+	}
+	for {
+		record := Record{}
+		err := records.Decode(&record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if record.Pic[ImageSize] != 200 || record.Pic[1] != 0 {
+			t.Errorf("Expected row-major pixels of %v", record.Char)
+		}
+	}
+
+	if err := Migrate(fileName); err != dataset.ErrUpToDate {
+		t.Errorf("Expected %v, got %v", dataset.ErrUpToDate, err)
+	}
+}
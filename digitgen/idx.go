@@ -0,0 +1,55 @@
+package digitgen
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mrfuxi/digit/dataset"
+)
+
+// MnistLabels is label map of IDX files of MNIST: index of label is the digit
+func MnistLabels() []string {
+	labels := make([]string, 10)
+	for digit := range labels {
+		labels[digit] = strconv.Itoa(digit)
+	}
+	return labels
+}
+
+// ExportIDX writes records of data file as gzip compressed IDX files of images and labels.
+// Labels are indexes in label map of data file, which is returned.
+func ExportIDX(fileName, imagesFile, labelsFile string) ([]string, error) {
+	var indexes map[string]int
+	return dataset.ExportIDX(fileName, dataset.KindDigit, ImageSize, imagesFile, labelsFile, func(r *dataset.Reader) ([]uint8, int, error) {
+		if indexes == nil {
+			indexes = map[string]int{}
+			for i, label := range r.Header.Labels {
+				indexes[label] = i
+			}
+		}
+
+		record := Record{}
+		if err := r.Decode(&record); err != nil {
+			return nil, 0, err
+		}
+		label, ok := indexes[record.Char]
+		if !ok {
+			return nil, 0, fmt.Errorf("Unknown label %q", record.Char)
+		}
+		return record.Pic[:], label, nil
+	})
+}
+
+// ImportIDX writes images of IDX files (e.g. MNIST, EMNIST) as data file, with labels given by label map.
+// Imported chars are taken for true handwriting, except BlankChar.
+func ImportIDX(imagesFile, labelsFile, fileName string, labels []string, transpose bool) error {
+	header := header(labels, map[string]string{"source": imagesFile})
+	return dataset.ImportIDX(imagesFile, labelsFile, fileName, len(labels), header, transpose, func(pic []uint8, label int) interface{} {
+		record := Record{Char: labels[label], Type: FTypeTrueHand}
+		if record.Char == BlankChar {
+			record.Type = FTypeBlank
+		}
+		copy(record.Pic[:], pic)
+		return record
+	})
+}
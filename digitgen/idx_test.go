@@ -0,0 +1,85 @@
+package digitgen
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mrfuxi/digit/dataset"
+)
+
+func TestIDXRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digitgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := path.Join(dir, "train.dat")
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := dataset.NewWriter(file, header([]string{"7", "A", BlankChar}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, char := range []string{"A", BlankChar, "7"} {
+		record := Record{Char: char, Type: FTypeMachine}
+		record.Pic[1] = 200
+		if err := w.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	imagesFile, labelsFile := path.Join(dir, "images.gz"), path.Join(dir, "labels.gz")
+	labels, err := ExportIDX(fileName, imagesFile, labelsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := path.Join(dir, "imported.dat")
+	if err := ImportIDX(imagesFile, labelsFile, imported, labels, true); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := dataset.Open(imported, dataset.KindDigit, ImageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer records.Close()
+	if records.Header.Count != 3 || records.Header.Params["source"] != imagesFile {
+		t.Errorf("Unexpected header %+v", records.Header)
+	}
+	expected := []Record{{Char: "A", Type: FTypeTrueHand}, {Char: BlankChar, Type: FTypeBlank}, {Char: "7", Type: FTypeTrueHand}}
+	for _, e := range expected {
+		record := Record{}
+		if err := records.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Char != e.Char || record.Type != e.Type {
+			t.Errorf("Expected %v %v, got %v %v", e.Type, e.Char, record.Type, record.Char)
+		}
+		// Transposed while imported
+		if record.Pic[ImageSize] != 200 || record.Pic[1] != 0 {
+			t.Errorf("Expected transposed pixels of %v", record.Char)
+		}
+	}
+	if err := records.Decode(&Record{}); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	failed := path.Join(dir, "failed.dat")
+	if err := ImportIDX(imagesFile, labelsFile, failed, labels[:1], false); err == nil {
+		t.Error("Expected labels out of label map to be reported")
+	}
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Errorf("Expected partly written file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package digitgen
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+type statsKey struct {
+	Type FType
+	Char string
+}
+
+type statsCount struct {
+	Train int
+	Test  int
+}
+
+// Stats counts records saved to train and test files
+type Stats struct {
+	counts      map[statsKey]*statsCount
+	trainHashes map[uint64]bool
+	testHashes  map[uint64]bool
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		counts:      map[statsKey]*statsCount{},
+		trainHashes: map[uint64]bool{},
+		testHashes:  map[uint64]bool{},
+	}
+}
+
+// Add counts record saved to train or test file
+func (s *Stats) Add(record Record, train bool) {
+	key := statsKey{Type: record.Type, Char: record.Char}
+	count, ok := s.counts[key]
+	if !ok {
+		count = &statsCount{}
+		s.counts[key] = count
+	}
+
+	hash := fnv.New64a()
+	hash.Write(record.Pic[:])
+	if train {
+		count.Train++
+		s.trainHashes[hash.Sum64()] = true
+	} else {
+		count.Test++
+		s.testHashes[hash.Sum64()] = true
+	}
+}
+
+// Leaked returns number of distinct test images also present in train file
+func (s *Stats) Leaked() int {
+	leaked := 0
+	for hash := range s.testHashes {
+		if s.trainHashes[hash] {
+			leaked++
+		}
+	}
+	return leaked
+}
+
+// Print writes summary of records per font type and character
+func (s *Stats) Print(w io.Writer) {
+	keys := make([]statsKey, 0, len(s.counts))
+	for key := range s.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		return keys[i].Char < keys[j].Char
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Type\tChar\tTrain\tTest\t")
+
+	total := statsCount{}
+	typeTotal := statsCount{}
+	for i, key := range keys {
+		count := s.counts[key]
+		fmt.Fprintf(tw, "%v\t%v\t%d\t%d\t\n", key.Type, key.Char, count.Train, count.Test)
+
+		typeTotal.Train += count.Train
+		typeTotal.Test += count.Test
+		if i == len(keys)-1 || keys[i+1].Type != key.Type {
+			fmt.Fprintf(tw, "%v\t%v\t%d\t%d\t\n", key.Type, "all", typeTotal.Train, typeTotal.Test)
+			total.Train += typeTotal.Train
+			total.Test += typeTotal.Test
+			typeTotal = statsCount{}
+		}
+	}
+	fmt.Fprintf(tw, "%v\t%v\t%d\t%d\t\n", "all", "all", total.Train, total.Test)
+	tw.Flush()
+
+	fmt.Fprintln(w, "Test images also present in train:", s.Leaked())
+}
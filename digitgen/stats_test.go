@@ -0,0 +1,52 @@
+package digitgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := NewStats()
+
+	one := Record{Char: "1", Type: FTypeMachine}
+	one.Pic[0] = 255
+	two := Record{Char: "2", Type: FTypeMachine}
+	two.Pic[1] = 255
+	mnist := Record{Char: "1", Type: FTypeTrueHand}
+	mnist.Pic[2] = 255
+
+	stats.Add(one, true)
+	stats.Add(two, true)
+	stats.Add(two, false)
+	stats.Add(mnist, false)
+
+	if leaked := stats.Leaked(); leaked != 1 {
+		t.Errorf("Expected 1 leaked image, got %v", leaked)
+	}
+
+	buf := bytes.Buffer{}
+	stats.Print(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	expected := [][]string{
+		{"Type", "Char", "Train", "Test"},
+		{"machine", "1", "1", "0"},
+		{"machine", "2", "1", "1"},
+		{"machine", "all", "2", "1"},
+		{"true-hand", "1", "0", "1"},
+		{"true-hand", "all", "0", "1"},
+		{"all", "all", "2", "2"},
+	}
+	if len(lines) != len(expected)+1 {
+		t.Fatalf("Unexpected summary:\n%v", buf.String())
+	}
+	for i, fields := range expected {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(fields, " ") {
+			t.Errorf("Line %v: expected %v, got %v", i, fields, got)
+		}
+	}
+	if lines[len(lines)-1] != "Test images also present in train: 1" {
+		t.Errorf("Unexpected leak summary %q", lines[len(lines)-1])
+	}
+}
@@ -1,113 +1,228 @@
 package digitnet
 
 import (
-	"encoding/gob"
 	"fmt"
+	"image"
 	"io"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
 	"github.com/mrfuxi/digit/digitgen"
 	"github.com/mrfuxi/neural"
 )
 
-var (
-	inputSize = 28 * 28
-)
+const inputSize = digitgen.ImageSize * digitgen.ImageSize
 
-func prepareMnistData(r io.Reader) (examples []neural.TrainExample) {
-	dec := gob.NewDecoder(r)
+// DefaultLabels are labels of data files written without label map: digits 0-9 and empty cell
+var DefaultLabels = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", digitgen.BlankChar}
 
-	for {
-		tmp := digitgen.Record{}
-		err := dec.Decode(&tmp)
-		if err == io.EOF {
+// LegacyLabels are labels of networks saved without architecture: digits 0-9
+var LegacyLabels = DefaultLabels[:10]
+
+// FileLabels returns label map of data file
+func FileLabels(fileName string) ([]string, error) {
+	records, err := dataset.Open(fileName, dataset.KindDigit, digitgen.ImageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+
+	if records.Header.Labels == nil {
+		return DefaultLabels, nil
+	}
+	return records.Header.Labels, nil
+}
+
+// SpecLabels returns labels of network outputs. Networks saved without label map
+// have the first of DefaultLabels.
+func SpecLabels(spec common.ModelSpec) []string {
+	if spec.Labels != nil {
+		return spec.Labels
+	}
+	if spec.Output <= len(DefaultLabels) {
+		return DefaultLabels[:spec.Output]
+	}
+	return DefaultLabels
+}
+
+// DigitOutput converts network output to probabilities of digits 0-9, indexed by digit.
+// Returns true if empty cell is the most probable.
+func DigitOutput(labels []string, output []float64) ([]float64, bool) {
+	digits := make([]float64, 10)
+	for i, label := range labels {
+		if i >= len(output) {
 			break
-		} else if err != nil {
-			panic(err)
 		}
-		image := tmp.Pic
-		label, err := strconv.Atoi(tmp.Char)
-		if err != nil {
-			label = 0
+		if len(label) == 1 && label[0] >= '0' && label[0] <= '9' {
+			digits[label[0]-'0'] = output[i]
 		}
+	}
+	best := common.Argmax(output)
+	return digits, best < len(labels) && labels[best] == digitgen.BlankChar
+}
 
-		example := neural.TrainExample{
-			Input:  make([]float64, inputSize, inputSize),
-			Output: make([]float64, 10, 10),
-		}
+// recordReader reads samples of digit data file. Index of label in labels is expected output of network.
+type recordReader struct {
+	records *dataset.Reader
+	indexes map[string]int
+}
 
-		for j, pix := range image {
-			example.Input[j] = (float64(pix)/255)*0.9 + 0.1
-		}
+func openRecords(fileName string, labels []string) (common.SampleReader, error) {
+	records, err := dataset.Open(fileName, dataset.KindDigit, digitgen.ImageSize)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordReader(records, labels), nil
+}
 
-		for j := range example.Output {
-			example.Output[j] = 0
-		}
-		example.Output[label] = 1
-		examples = append(examples, example)
+func newRecordReader(records *dataset.Reader, labels []string) *recordReader {
+	indexes := map[string]int{}
+	for i, label := range labels {
+		indexes[label] = i
 	}
-	return
+	return &recordReader{records: records, indexes: indexes}
 }
 
-func loadTrainData() ([]neural.TrainExample, []neural.TrainExample) {
-	trainFile, err := os.Open(digitgen.TrainFile)
-	if err != nil {
-		panic(err)
+// Read returns next sample, with its label and font type as stratum used when data is split
+func (r *recordReader) Read() (common.Sample, error) {
+	tmp := digitgen.Record{}
+	if err := r.records.Decode(&tmp); err != nil {
+		return common.Sample{}, err
 	}
-	defer trainFile.Close()
-	tmp := prepareMnistData(trainFile)
-	trainData := tmp[:len(tmp)-10000]
-	validationData := tmp[len(tmp)-10000:]
-	return trainData, validationData
+	dataset.FixLayout(r.records.Header.Version, tmp.Pic[:], digitgen.ImageSize)
+	label, ok := r.indexes[tmp.Char]
+	if !ok {
+		return common.Sample{}, fmt.Errorf("Unknown label %q", tmp.Char)
+	}
+	return common.Sample{Pic: tmp.Pic[:], Label: label, Stratum: fmt.Sprintf("%v %v", label, tmp.Type)}, nil
+}
+
+func (r *recordReader) Close() error {
+	return r.records.Close()
 }
 
-func loadTestData() []neural.TrainExample {
-	testFile, err := os.Open(digitgen.TestFile)
+// countRecords returns number of records stored in header of data file, dataset.CountUnknown for legacy files
+func countRecords(fileName string) (int, error) {
+	header, err := dataset.ReadFileHeader(fileName)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	defer testFile.Close()
+	return header.Count, header.Check(dataset.KindDigit, digitgen.ImageSize)
+}
+
+// Data returns dataset of digit data files for network with outputs for labels
+func Data(labels []string) common.Dataset {
+	return common.Dataset{
+		Open: func(fileName string) (common.SampleReader, error) {
+			return openRecords(fileName, labels)
+		},
+		Count:   countRecords,
+		Encode:  encodePic,
+		Inputs:  inputSize,
+		Outputs: len(labels),
+	}
+}
+
+func encodePic(dst []float64, pic []uint8) {
+	for j, pix := range pic {
+		dst[j] = (float64(pix)/255)*0.9 + 0.1
+	}
+}
 
-	testData := prepareMnistData(testFile)
-	return testData
+// ImageInput converts image of any size to network input.
+// Image is expected to have light digit on dark background.
+func ImageInput(img image.Image) []float64 {
+	scaled := common.Resize(img, digitgen.ImageSize, digitgen.ImageSize)
+	pic := digitgen.ImageToPic(scaled)
+
+	input := make([]float64, inputSize, inputSize)
+	encodePic(input, pic[:])
+	return input
 }
 
-func BuildNN() neural.Evaluator {
-	activator := neural.NewSigmoidActivator()
-	outActivator := neural.NewSoftmaxActivator()
-	nn := neural.NewNeuralNetwork(
-		[]int{inputSize, 100, 10},
-		neural.NewFullyConnectedLayer(activator),
-		neural.NewFullyConnectedLayer(outActivator),
-	)
-	return nn
+// Recognize returns index of most probable label of image along with network output for all labels
+func Recognize(nn neural.Evaluator, img image.Image) (int, []float64) {
+	output := nn.Evaluate(ImageInput(img))
+	return common.Argmax(output), output
 }
 
-func RunTraining(nn neural.Evaluator) {
-	fmt.Println("Loading train data")
-	testData := loadTestData()
-	trainData, validationData := loadTrainData()
+// DefaultSpec returns architecture of networks saved without it, with output for each of LegacyLabels.
+// New networks get output for each label of their data, see LabelSpec.
+func DefaultSpec() common.ModelSpec {
+	return LabelSpec(LegacyLabels)
+}
 
-	cost := neural.NewLogLikelihoodCost()
-	options := neural.TrainOptions{
+// LabelSpec returns default architecture of network with output for each label
+func LabelSpec(labels []string) common.ModelSpec {
+	return common.ModelSpec{
+		Input:            inputSize,
+		Hidden:           []int{100},
+		Activations:      []string{common.ActivationSigmoid},
+		Output:           len(labels),
+		OutputActivation: common.ActivationSoftmax,
+		Labels:           labels,
+	}
+}
+
+// DefaultTraining returns hyperparameters used when none are given
+func DefaultTraining() common.TrainingSpec {
+	return common.TrainingSpec{
 		Epochs:         20,
 		MiniBatchSize:  10,
 		LearningRate:   0.01,
 		Regularization: 2,
 		Momentum:       0.9,
-		TrainerFactory: neural.NewBackpropagationTrainer,
-		EpocheCallback: common.EpocheCallback(nn, cost, validationData, testData),
-		Cost:           cost,
+		Cost:           common.CostLogLikelihood,
+		Trainer:        common.TrainerBackprop,
+		Noise:          common.DefaultNoise,
+		Validation:     0.1,
+	}
+}
+
+// RunTraining trains network of spec using data from trainFile, reporting progress on test data from testFile.
+// Randomized trainer adds noise to samples of all labels.
+func RunTraining(nn neural.Evaluator, spec common.ModelSpec, trainFile, testFile string) error {
+	labels := SpecLabels(spec)
+	training := DefaultTraining()
+	if spec.Training != nil {
+		training = *spec.Training
+	}
+	options, err := training.TrainOptions()
+	if err != nil {
+		return err
 	}
 
 	fmt.Println("Start training")
 
 	t0 := time.Now()
-	neural.Train(nn, trainData, options)
+	if err := common.TrainNN(nn, spec, training, options, Data(labels), trainFile, testFile); err != nil {
+		return err
+	}
 	dt := time.Since(t0)
 
 	fmt.Println("Training complete in", dt)
+	return nil
+}
+
+// Evaluate runs network with outputs for labels over data file and returns confusion matrix of results
+func Evaluate(nn neural.Evaluator, labels []string, fileName string) (*common.Confusion, error) {
+	records, err := openRecords(fileName, labels)
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+
+	confusion := common.NewConfusion(labels)
+	input := make([]float64, inputSize)
+	for {
+		sample, err := records.Read()
+		if err == io.EOF {
+			return confusion, nil
+		} else if err != nil {
+			return nil, err
+		}
+		encodePic(input, sample.Pic)
+		confusion.Add(sample.Label, common.Argmax(nn.Evaluate(input)))
+	}
 }
@@ -0,0 +1,154 @@
+package digitnet
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
+	"github.com/mrfuxi/digit/digitgen"
+	"github.com/mrfuxi/neural"
+)
+
+func dataFile(t *testing.T, labels []string, chars ...string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	header := dataset.Header{Kind: dataset.KindDigit, Width: digitgen.ImageSize, Height: digitgen.ImageSize, Labels: labels}
+	w, err := dataset.NewWriter(buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, char := range chars {
+		if err := w.Encode(digitgen.Record{Char: char, Type: digitgen.FTypeMachine}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf
+}
+
+func newTestReader(t *testing.T, labels []string, chars ...string) *recordReader {
+	records, err := dataset.NewReader(dataFile(t, labels, chars...), dataset.KindDigit, digitgen.ImageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRecordReader(records, labels)
+}
+
+func readAll(t *testing.T, r common.SampleReader) ([]common.Sample, error) {
+	var samples []common.Sample
+	for {
+		sample, err := r.Read()
+		if err == io.EOF {
+			return samples, nil
+		} else if err != nil {
+			return samples, err
+		}
+		samples = append(samples, sample)
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	labels := []string{"A", "B", "0"}
+	samples, err := readAll(t, newTestReader(t, labels, "B", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || len(samples[0].Pic) != inputSize {
+		t.Fatalf("Expected 2 samples of %d pixels, got %v", inputSize, samples)
+	}
+	if samples[0].Label != 1 || samples[1].Label != 2 {
+		t.Errorf("Expected labels B and 0 at their indexes, got %v and %v", samples[0].Label, samples[1].Label)
+	}
+	if samples[0].Stratum != "1 machine" {
+		t.Errorf("Expected stratum of label and font type, got %q", samples[0].Stratum)
+	}
+
+	if _, err := readAll(t, newTestReader(t, labels, "A", "x")); err == nil || err.Error() != `Unknown label "x"` {
+		t.Errorf("Expected unknown label to be an error, got %v", err)
+	}
+}
+
+func TestSpecLabels(t *testing.T) {
+	if got := SpecLabels(LabelSpec([]string{"A", "B"})); len(got) != 2 || got[1] != "B" {
+		t.Errorf("Expected stored labels, got %v", got)
+	}
+
+	old := common.ModelSpec{Output: 10}
+	if got := SpecLabels(old); len(got) != 10 || got[9] != "9" {
+		t.Errorf("Expected digits for network saved without labels, got %v", got)
+	}
+	if spec := DefaultSpec(); spec.Output != 10 || len(SpecLabels(spec)) != 10 {
+		t.Errorf("Expected 10 outputs of network saved without architecture, got %v", spec.Output)
+	}
+}
+
+func TestDigitOutput(t *testing.T) {
+	labels := []string{"9", "1", digitgen.BlankChar, "A"}
+
+	digits, blank := DigitOutput(labels, []float64{0.5, 0.2, 0.1, 0.2})
+	if blank || len(digits) != 10 || digits[9] != 0.5 || digits[1] != 0.2 || digits[0] != 0 {
+		t.Errorf("Expected digits indexed by value, got %v (blank %v)", digits, blank)
+	}
+
+	if _, blank := DigitOutput(labels, []float64{0.1, 0.1, 0.7, 0.1}); !blank {
+		t.Error("Expected empty cell")
+	}
+}
+
+type fixedEvaluator struct {
+	neural.Evaluator
+	output []float64
+	input  []float64
+}
+
+func (f *fixedEvaluator) Evaluate(input []float64) []float64 {
+	f.input = input
+	return f.output
+}
+
+func TestImageInput(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, digitgen.ImageSize, digitgen.ImageSize))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	pic := digitgen.ImageToPic(img)
+	expected := make([]float64, inputSize)
+	encodePic(expected, pic[:])
+
+	input := ImageInput(img)
+	if len(input) != inputSize {
+		t.Fatalf("Expected %v inputs, got %v", inputSize, len(input))
+	}
+	for i := range input {
+		if input[i] != expected[i] {
+			t.Fatalf("Expected input of 28x28 image to match its pixels, differs at %v: %v != %v", i, input[i], expected[i])
+		}
+	}
+
+	// Twice as large image with each pixel repeated gives the same input
+	large := image.NewGray(image.Rect(0, 0, 2*digitgen.ImageSize, 2*digitgen.ImageSize))
+	for y := 0; y < 2*digitgen.ImageSize; y++ {
+		for x := 0; x < 2*digitgen.ImageSize; x++ {
+			large.Pix[large.PixOffset(x, y)] = img.Pix[img.PixOffset(x/2, y/2)]
+		}
+	}
+	for i, v := range ImageInput(large) {
+		if v != expected[i] {
+			t.Fatalf("Expected scaled image to give the same input, differs at %v: %v != %v", i, v, expected[i])
+		}
+	}
+}
+
+func TestRecognize(t *testing.T) {
+	nn := &fixedEvaluator{output: []float64{0.1, 0.7, 0.2}}
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+
+	label, output := Recognize(nn, img)
+	if label != 1 || len(output) != 3 {
+		t.Errorf("Expected label 1 of 3 outputs, got %v of %v", label, output)
+	}
+	if len(nn.input) != inputSize || nn.input[0] != 0.1 {
+		t.Errorf("Expected network to get encoded image, got %v inputs starting with %v", len(nn.input), nn.input[0])
+	}
+}
@@ -0,0 +1,116 @@
+package gridgen
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/mrfuxi/digit/common"
+)
+
+// Degradation describes random distortions applied to drawn fragments,
+// so they look like fragments of grid on a photo
+type Degradation struct {
+	Perspective common.AugmentRange // Longest move of image corner in pixels
+	BorderWidth common.AugmentRange // Width of outer border of grid in pixels
+	LineWidth   common.AugmentRange // Width of inner lines in pixels
+	Invert      common.AugmentRange // Brightness of paper (0-1) when lines are dark on light paper
+	Lighting    common.AugmentRange // Fraction of brightness lost on the dark side of image
+	Blur        common.AugmentRange // Standard deviation of gaussian in pixels
+	JPEG        common.AugmentRange // Quality of JPEG compression (1-100)
+}
+
+// DefaultDegradation returns distortions used when degradation is turned on
+func DefaultDegradation() Degradation {
+	return Degradation{
+		Perspective: common.AugmentRange{Probability: 0.5, Min: 0, Max: 3},
+		BorderWidth: common.AugmentRange{Probability: 0.5, Min: 2, Max: 5},
+		LineWidth:   common.AugmentRange{Probability: 0.5, Min: 1, Max: 3},
+		Invert:      common.AugmentRange{Probability: 0.5, Min: 0.6, Max: 1},
+		Lighting:    common.AugmentRange{Probability: 0.5, Min: 0, Max: 0.6},
+		Blur:        common.AugmentRange{Probability: 0.3, Min: 0.3, Max: 1},
+		JPEG:        common.AugmentRange{Probability: 0.3, Min: 20, Max: 80},
+	}
+}
+
+func (d Degradation) String() string {
+	return fmt.Sprintf(
+		"perspective %v, border width %v, line width %v, invert %v, lighting %v, blur %v, jpeg %v",
+		d.Perspective, d.BorderWidth, d.LineWidth, d.Invert, d.Lighting, d.Blur, d.JPEG,
+	)
+}
+
+// Apply distorts image. Same rng state gives the same result.
+func (d Degradation) Apply(img Image, rng *rand.Rand) *image.Gray {
+	gray := common.ToGray(img.Image)
+
+	widths := lineWidths{border: lineWidth, inner: lineWidth}
+	border, thick := d.BorderWidth.Pick(rng)
+	if thick {
+		widths.border = border
+	}
+	inner, thin := d.LineWidth.Pick(rng)
+	if thin {
+		widths.inner = inner
+	}
+	if (thick || thin) && img.render != nil {
+		gray = common.ToGray(drawBaseWidth(img.render, widths))
+	}
+
+	if move, ok := d.Perspective.Pick(rng); ok {
+		corners := []common.Point{{X: 0, Y: 0}, {X: ImageSize, Y: 0}, {X: ImageSize, Y: ImageSize}, {X: 0, Y: ImageSize}}
+		moved := make([]common.Point, len(corners))
+		for i, corner := range corners {
+			moved[i] = common.Point{
+				X: corner.X + (rng.Float64()*2-1)*move,
+				Y: corner.Y + (rng.Float64()*2-1)*move,
+			}
+		}
+		if h, err := common.FitHomography(corners, moved); err == nil {
+			if warped, err := common.Warp(gray, h); err == nil {
+				gray = warped
+			}
+		}
+	}
+
+	if paper, ok := d.Invert.Pick(rng); ok {
+		for i, pix := range gray.Pix {
+			gray.Pix[i] = uint8(paper*float64(255-pix) + 0.5)
+		}
+	}
+
+	if loss, ok := d.Lighting.Pick(rng); ok {
+		sin, cos := math.Sincos(rng.Float64() * 2 * math.Pi)
+		center := float64(ImageSize) / 2
+		for y := 0; y < ImageSize; y++ {
+			for x := 0; x < ImageSize; x++ {
+				// Position along direction of light, from 0 (bright side) to 1 (dark side)
+				t := ((float64(x)-center)*cos+(float64(y)-center)*sin)/ImageSize + 0.5
+				t = math.Min(1, math.Max(0, t))
+				offset := gray.PixOffset(x, y)
+				gray.Pix[offset] = uint8(float64(gray.Pix[offset])*(1-loss*t) + 0.5)
+			}
+		}
+	}
+
+	if sigma, ok := d.Blur.Pick(rng); ok {
+		gray = common.GaussianBlur(gray, sigma)
+	}
+
+	if quality, ok := d.JPEG.Pick(rng); ok {
+		if recompressed, err := common.JPEGRecompress(gray, int(quality)); err == nil {
+			gray = recompressed
+		}
+	}
+	return gray
+}
+
+// degrade distorts images. Each image has its own random numbers generator,
+// so result does not depend on which routine distorted it.
+func degrade(degradation Degradation, images <-chan Image, degraded chan<- Image) {
+	for img := range images {
+		img.Image = degradation.Apply(img, rand.New(rand.NewSource(img.Seed)))
+		degraded <- img
+	}
+}
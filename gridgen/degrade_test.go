@@ -0,0 +1,41 @@
+package gridgen
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/mrfuxi/digit/common"
+)
+
+func lineImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, ImageSize, ImageSize))
+	for y := 0; y < ImageSize; y++ {
+		img.Pix[img.PixOffset(13, y)] = 255
+		img.Pix[img.PixOffset(14, y)] = 255
+	}
+	return img
+}
+
+func TestDegradationApply(t *testing.T) {
+	always := DefaultDegradation()
+	for _, r := range []*common.AugmentRange{&always.Perspective, &always.BorderWidth, &always.LineWidth, &always.Invert, &always.Lighting, &always.Blur, &always.JPEG} {
+		r.Probability = 1
+	}
+
+	first := always.Apply(Image{Image: lineImage()}, rand.New(rand.NewSource(3)))
+	second := always.Apply(Image{Image: lineImage()}, rand.New(rand.NewSource(3)))
+	if string(first.Pix) != string(second.Pix) {
+		t.Error("Expected the same seed to give the same image")
+	}
+
+	// Lines become dark on light paper
+	if first.GrayAt(0, 0).Y < 64 && first.GrayAt(ImageSize-1, ImageSize-1).Y < 64 {
+		t.Errorf("Expected light paper, got %v and %v", first.GrayAt(0, 0).Y, first.GrayAt(ImageSize-1, ImageSize-1).Y)
+	}
+
+	never := Degradation{}
+	if got := never.Apply(Image{Image: lineImage()}, rand.New(rand.NewSource(3))); string(got.Pix) != string(lineImage().Pix) {
+		t.Error("Expected image to be left untouched without distortions")
+	}
+}
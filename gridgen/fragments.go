@@ -1,6 +1,7 @@
 package gridgen
 
 import (
+	"fmt"
 	"image"
 	"math"
 	"math/rand"
@@ -32,6 +33,41 @@ const (
 	FragmentSuperTypeCross
 )
 
+var fragmentTypeNames = []string{"empty", "corner-nw", "corner-ne", "corner-se", "corner-sw", "edge-n", "edge-e", "edge-s", "edge-w", "cross"}
+var fragmentSuperTypeNames = []string{"empty", "corner", "edge", "cross"}
+
+// FragmentTypes lists all fragment types
+var FragmentTypes = []FragmentType{
+	FragmentTypeEmpty,
+	FragmentTypeCornerNW, FragmentTypeCornerNE, FragmentTypeCornerSE, FragmentTypeCornerSW,
+	FragmentTypeEdgeN, FragmentTypeEdgeE, FragmentTypeEdgeS, FragmentTypeEdgeW,
+	FragmentTypeCross,
+}
+
+// FragmentSuperTypes lists all fragment super types
+var FragmentSuperTypes = []FragmentSuperType{
+	FragmentSuperTypeEmpty, FragmentSuperTypeCorner, FragmentSuperTypeEdge, FragmentSuperTypeCross,
+}
+
+// Labels returns names of fragment types in order of their values, used as label map of data files
+func Labels() []string {
+	return append([]string{}, fragmentTypeNames...)
+}
+
+func (f FragmentType) String() string {
+	if int(f) < len(fragmentTypeNames) {
+		return fragmentTypeNames[f]
+	}
+	return fmt.Sprintf("FragmentType(%d)", uint8(f))
+}
+
+func (f FragmentSuperType) String() string {
+	if int(f) < len(fragmentSuperTypeNames) {
+		return fragmentSuperTypeNames[f]
+	}
+	return fmt.Sprintf("FragmentSuperType(%d)", uint8(f))
+}
+
 func IsCorner(fragment FragmentType) bool {
 	switch fragment {
 	case FragmentTypeCornerNW:
@@ -87,14 +123,52 @@ func FragmentTypeToSuper(fragment FragmentType) FragmentSuperType {
 	}
 }
 
+// LatticeFragment returns fragment type found at intersection of lines in grid of size x size cells.
+// Column and row are counted from top left corner of grid and go from 0 to size inclusive.
+func LatticeFragment(col, row, size int) FragmentType {
+	switch {
+	case col == 0 && row == 0:
+		return FragmentTypeCornerNW
+	case col == size && row == 0:
+		return FragmentTypeCornerNE
+	case col == size && row == size:
+		return FragmentTypeCornerSE
+	case col == 0 && row == size:
+		return FragmentTypeCornerSW
+	case row == 0:
+		return FragmentTypeEdgeN
+	case col == size:
+		return FragmentTypeEdgeE
+	case row == size:
+		return FragmentTypeEdgeS
+	case col == 0:
+		return FragmentTypeEdgeW
+	default:
+		return FragmentTypeCross
+	}
+}
+
 type Drawer interface {
-	Draw(images chan<- Image)
+	Draw(rng *rand.Rand, images chan<- Image)
 	Count() int
 }
 
-type contextDrawFunc func(gc *draw2dimg.GraphicContext)
+// lineWidths are widths of grid lines in pixels. Outer border of grid may be thicker than inner lines.
+type lineWidths struct {
+	border float64
+	inner  float64
+}
+
+type contextDrawFunc func(gc *draw2dimg.GraphicContext, widths lineWidths)
+
+// lineWidth is width of grid lines in pixels
+const lineWidth = 2
 
 func drawBase(dr contextDrawFunc) image.Image {
+	return drawBaseWidth(dr, lineWidths{border: lineWidth, inner: lineWidth})
+}
+
+func drawBaseWidth(dr contextDrawFunc, widths lineWidths) image.Image {
 	center := ImageSize / 2.0
 
 	canvas := image.NewRGBA(image.Rect(0, 0, ImageSize, ImageSize))
@@ -103,11 +177,10 @@ func drawBase(dr contextDrawFunc) image.Image {
 
 	gc.DrawImage(image.Black)      // Background color
 	gc.SetStrokeColor(image.White) // Line color
-	gc.SetLineWidth(2)
 
 	gc.Translate(center, center)
 
-	dr(gc)
+	dr(gc, widths)
 
 	return canvas
 }
@@ -117,20 +190,22 @@ type cornerDrawer struct {
 	Angles    []float64
 }
 
-func (c *cornerDrawer) Draw(images chan<- Image) {
+func (c *cornerDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	for _, fragment := range []FragmentType{FragmentTypeCornerNW, FragmentTypeCornerNE, FragmentTypeCornerSE, FragmentTypeCornerSW} {
 		for _, ds := range c.Angles {
 			for _, dd := range c.Angles {
 				for _, dx := range c.Movements {
 					for _, dy := range c.Movements {
+						render := c.drawFragment(fragment, dx, dy, ds, dd)
 						images <- Image{
 							GridInfo: GridInfo{
 								Fragment:      fragment,
 								FragmentSuper: FragmentTypeToSuper(fragment),
-								Train:         rand.Intn(100) >= 5,
+								Train:         rng.Intn(100) >= 5,
 							},
-							Image:     drawBase(c.drawFragment(fragment, dx, dy, ds, dd)),
+							Image:     drawBase(render),
 							OffCenter: math.Max(math.Abs(dx), math.Abs(dy)),
+							render:    render,
 						}
 					}
 				}
@@ -144,7 +219,7 @@ func (c *cornerDrawer) Count() int {
 }
 
 func (c *cornerDrawer) drawFragment(fragment FragmentType, dx, dy, dStartAngle, dDiffAngle float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
 		var startAngle float64
 		var diffAngle float64 = 90
 
@@ -164,6 +239,7 @@ func (c *cornerDrawer) drawFragment(fragment FragmentType, dx, dy, dStartAngle,
 		startAngle += dStartAngle
 		diffAngle += dDiffAngle
 
+		gc.SetLineWidth(widths.border)
 		gc.Translate(dx, dy)
 		gc.Rotate(startAngle * math.Pi / 180.0)
 
@@ -186,20 +262,22 @@ type edgeDrawer struct {
 	Angles    []float64
 }
 
-func (e *edgeDrawer) Draw(images chan<- Image) {
+func (e *edgeDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	for _, fragment := range []FragmentType{FragmentTypeEdgeN, FragmentTypeEdgeE, FragmentTypeEdgeS, FragmentTypeEdgeW} {
 		for _, ds := range e.Angles {
 			for _, dd := range e.Angles {
 				for _, dx := range e.Movements {
 					for _, dy := range e.Movements {
+						render := e.drawFragment(fragment, dx, dy, ds, dd)
 						images <- Image{
 							GridInfo: GridInfo{
 								Fragment:      fragment,
 								FragmentSuper: FragmentTypeToSuper(fragment),
-								Train:         rand.Intn(100) >= 5,
+								Train:         rng.Intn(100) >= 5,
 							},
-							Image:     drawBase(e.drawFragment(fragment, dx, dy, ds, dd)),
+							Image:     drawBase(render),
 							OffCenter: math.Max(math.Abs(dx), math.Abs(dy)),
+							render:    render,
 						}
 					}
 				}
@@ -213,7 +291,7 @@ func (e *edgeDrawer) Count() int {
 }
 
 func (e *edgeDrawer) drawFragment(fragment FragmentType, dx, dy, dStartAngle, dDiffAngle float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
 		var startAngle float64
 		var diffAngle float64 = 90
 
@@ -233,6 +311,7 @@ func (e *edgeDrawer) drawFragment(fragment FragmentType, dx, dy, dStartAngle, dD
 		startAngle += dStartAngle
 		diffAngle += dDiffAngle
 
+		gc.SetLineWidth(widths.border)
 		gc.Translate(dx, dy)
 		gc.Rotate(startAngle * math.Pi / 180.0)
 
@@ -241,6 +320,7 @@ func (e *edgeDrawer) drawFragment(fragment FragmentType, dx, dy, dStartAngle, dD
 		gc.Close()
 		gc.FillStroke()
 
+		gc.SetLineWidth(widths.inner)
 		gc.Rotate(diffAngle * math.Pi / 180.0)
 
 		gc.MoveTo(0, 0)
@@ -255,20 +335,22 @@ type crossDrawer struct {
 	Angles    []float64
 }
 
-func (c *crossDrawer) Draw(images chan<- Image) {
+func (c *crossDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	fragment := FragmentTypeCross
 	for _, ds := range c.Angles {
 		for _, dd := range c.Angles {
 			for _, dx := range c.Movements {
 				for _, dy := range c.Movements {
+					render := c.drawFragment(dx, dy, ds, dd)
 					images <- Image{
 						GridInfo: GridInfo{
 							Fragment:      fragment,
 							FragmentSuper: FragmentTypeToSuper(fragment),
-							Train:         rand.Intn(100) >= 5,
+							Train:         rng.Intn(100) >= 5,
 						},
-						Image:     drawBase(c.drawFragment(dx, dy, ds, dd)),
+						Image:     drawBase(render),
 						OffCenter: math.Max(math.Abs(dx), math.Abs(dy)),
+						render:    render,
 					}
 				}
 			}
@@ -281,13 +363,14 @@ func (c *crossDrawer) Count() int {
 }
 
 func (c *crossDrawer) drawFragment(dx, dy, dStartAngle, dDiffAngle float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
 		var startAngle float64
 		var diffAngle float64 = 90
 
 		startAngle += dStartAngle
 		diffAngle += dDiffAngle
 
+		gc.SetLineWidth(widths.inner)
 		gc.Translate(dx, dy)
 		gc.Rotate(startAngle * math.Pi / 180.0)
 
@@ -310,18 +393,20 @@ type lineDrawer struct {
 	Angles    []float64
 }
 
-func (l *lineDrawer) Draw(images chan<- Image) {
+func (l *lineDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	for _, horizontal := range []bool{true, false} {
 		for _, ds := range l.Angles {
 			for _, move := range l.Movements {
+				render := l.drawFragment(horizontal, move, ds)
 				images <- Image{
 					GridInfo: GridInfo{
 						Fragment:      FragmentTypeEmpty,
 						FragmentSuper: FragmentTypeToSuper(FragmentTypeEmpty),
-						Train:         rand.Intn(100) >= 5,
+						Train:         rng.Intn(100) >= 5,
 					},
-					Image:     drawBase(l.drawFragment(horizontal, move, ds)),
+					Image:     drawBase(render),
 					OffCenter: math.Abs(move),
+					render:    render,
 				}
 			}
 		}
@@ -333,7 +418,7 @@ func (l *lineDrawer) Count() int {
 }
 
 func (l *lineDrawer) drawFragment(horizontal bool, move, dStartAngle float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
 		var startAngle float64
 		var dx float64
 		var dy float64
@@ -348,6 +433,7 @@ func (l *lineDrawer) drawFragment(horizontal bool, move, dStartAngle float64) co
 
 		startAngle += dStartAngle
 
+		gc.SetLineWidth(widths.inner)
 		gc.Translate(dx, dy)
 		gc.Rotate(startAngle * math.Pi / 180.0)
 
@@ -363,7 +449,7 @@ type emptyDrawer struct {
 	Noise   float64
 }
 
-func (e *emptyDrawer) Draw(images chan<- Image) {
+func (e *emptyDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	fragment := FragmentTypeEmpty
 
 	images <- Image{
@@ -372,7 +458,7 @@ func (e *emptyDrawer) Draw(images chan<- Image) {
 			FragmentSuper: FragmentTypeToSuper(fragment),
 			Train:         true,
 		},
-		Image:     drawBase(e.drawFragment(0)),
+		Image:     drawBase(e.drawFragment(rng, 0)),
 		OffCenter: 0,
 	}
 
@@ -381,9 +467,9 @@ func (e *emptyDrawer) Draw(images chan<- Image) {
 			GridInfo: GridInfo{
 				Fragment:      fragment,
 				FragmentSuper: FragmentTypeToSuper(fragment),
-				Train:         rand.Intn(100) >= 5,
+				Train:         rng.Intn(100) >= 5,
 			},
-			Image:     drawBase(e.drawFragment(e.Noise)),
+			Image:     drawBase(e.drawFragment(rng, e.Noise)),
 			OffCenter: 0,
 		}
 	}
@@ -393,11 +479,12 @@ func (e *emptyDrawer) Count() int {
 	return e.Samples
 }
 
-func (e *emptyDrawer) drawFragment(noise float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+func (e *emptyDrawer) drawFragment(rng *rand.Rand, noise float64) contextDrawFunc {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
+		gc.SetLineWidth(widths.inner)
 		for i := 0; i < int(float64(ImageSize*ImageSize)*noise); i++ {
-			x := (rand.Float64() - 0.5) * ImageSize
-			y := (rand.Float64() - 0.5) * ImageSize
+			x := (rng.Float64() - 0.5) * ImageSize
+			y := (rng.Float64() - 0.5) * ImageSize
 
 			gc.MoveTo(x, y)
 			gc.LineTo(x+1, y)
@@ -412,7 +499,7 @@ type incompleteEdgeDrawer struct {
 	Angles    []float64
 }
 
-func (i *incompleteEdgeDrawer) Draw(images chan<- Image) {
+func (i *incompleteEdgeDrawer) Draw(rng *rand.Rand, images chan<- Image) {
 	dOff := 4.0
 	for _, fragment := range []FragmentType{FragmentTypeEdgeN, FragmentTypeEdgeE, FragmentTypeEdgeS, FragmentTypeEdgeW} {
 		for _, ds := range i.Angles {
@@ -421,14 +508,16 @@ func (i *incompleteEdgeDrawer) Draw(images chan<- Image) {
 					for _, dy := range i.Movements {
 						fr := FragmentTypeEmpty
 
+						render := i.drawFragment(fragment, dx, dy, dOff, ds, dd)
 						images <- Image{
 							GridInfo: GridInfo{
 								Fragment:      fr,
 								FragmentSuper: FragmentTypeToSuper(fr),
-								Train:         rand.Intn(100) >= 5,
+								Train:         rng.Intn(100) >= 5,
 							},
-							Image:     drawBase(i.drawFragment(fragment, dx, dy, dOff, ds, dd)),
+							Image:     drawBase(render),
 							OffCenter: math.Max(math.Max(math.Abs(dx), math.Abs(dy)), math.Abs(dOff)),
+							render:    render,
 						}
 
 					}
@@ -443,7 +532,7 @@ func (i *incompleteEdgeDrawer) Count() int {
 }
 
 func (i *incompleteEdgeDrawer) drawFragment(fragment FragmentType, dx, dy, dOff, dStartAngle, dDiffAngle float64) contextDrawFunc {
-	return func(gc *draw2dimg.GraphicContext) {
+	return func(gc *draw2dimg.GraphicContext, widths lineWidths) {
 		var startAngle float64
 		var diffAngle float64 = 90
 
@@ -463,6 +552,7 @@ func (i *incompleteEdgeDrawer) drawFragment(fragment FragmentType, dx, dy, dOff,
 		startAngle += dStartAngle
 		diffAngle += dDiffAngle
 
+		gc.SetLineWidth(widths.border)
 		gc.Translate(dx, dy)
 		gc.Rotate(startAngle * math.Pi / 180.0)
 
@@ -471,6 +561,7 @@ func (i *incompleteEdgeDrawer) drawFragment(fragment FragmentType, dx, dy, dOff,
 		gc.Close()
 		gc.FillStroke()
 
+		gc.SetLineWidth(widths.inner)
 		gc.Rotate(diffAngle * math.Pi / 180.0)
 
 		gc.MoveTo(dOff, 0)
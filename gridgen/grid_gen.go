@@ -1,15 +1,17 @@
 package gridgen
 
 import (
-	"encoding/gob"
 	"fmt"
 	"image"
-	"image/color"
+	"io"
+	"math/rand"
 	"os"
 	"path"
+	"sync"
 
 	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
 	"gopkg.in/cheggaaa/pb.v1"
 )
 
@@ -26,6 +28,10 @@ type Image struct {
 	GridInfo
 	Image     image.Image
 	OffCenter float64
+	Seq       int   // Position of image in output
+	Seed      int64 // Seed of random degradations
+
+	render contextDrawFunc // Draws lines of fragment again, nil for fragments without lines
 }
 
 type Counter struct {
@@ -34,22 +40,78 @@ type Counter struct {
 }
 
 type Record struct {
+	// Grayscale pixels in row-major layout, see dataset package
 	Pic           [ImageSize * ImageSize]uint8
 	Fragment      FragmentType
 	FragmentSuper FragmentSuperType
 }
 
+const (
+	DefaultOutDir = "out_grid"
+	TestFileName  = "test.dat"
+	TrainFileName = "train.dat"
+	PNGDirName    = "png"
+)
+
 var (
-	outDir    = path.Join("out_grid")
-	TestFile  = path.Join(outDir, "test.dat")
-	TrainFile = path.Join(outDir, "train.dat")
+	TestFile  = path.Join(DefaultOutDir, TestFileName)
+	TrainFile = path.Join(DefaultOutDir, TrainFileName)
 )
 
 var (
 	progress *pb.ProgressBar
 )
 
-func prepareMeta(drawers chan<- Drawer) {
+// Options of generating grid fragments
+type Options struct {
+	// Seed of random numbers generator. Same seed gives the same data files.
+	Seed int64
+
+	// Directory for generated data, removed before generating
+	OutDir string
+	// Remove output directory even if it was not created by generator
+	Force bool
+	// Data files, by default placed in output directory
+	TrainFile string
+	TestFile  string
+	// Save images as PNG files in per label directories of output directory
+	PNG bool
+	// Save only PNG files, without data files
+	PNGOnly bool
+	// Random photographic distortions of fragments, nil turns them off
+	Degradation *Degradation
+	// Directory of page images with JSON annotations of grid, like those written by pagegen.
+	// Fragments cut out of them are added to drawn ones.
+	PagesDir string
+	// Number of randomly moved crops taken around each intersection of page grid
+	PageShifts int
+}
+
+// setDefaults fills in paths which were not given
+func (o *Options) setDefaults() {
+	if o.OutDir == "" {
+		o.OutDir = DefaultOutDir
+	}
+	if o.TrainFile == "" {
+		o.TrainFile = path.Join(o.OutDir, TrainFileName)
+	}
+	if o.TestFile == "" {
+		o.TestFile = path.Join(o.OutDir, TestFileName)
+	}
+	if o.PNGOnly {
+		o.PNG = true
+	}
+}
+
+// drawJob is a drawer with its own random numbers generator and output,
+// so images do not depend on which drawing routine picked the job.
+type drawJob struct {
+	drawer Drawer
+	rng    *rand.Rand
+	images chan Image
+}
+
+func prepareMeta(rng *rand.Rand, extra []Drawer, jobs chan<- drawJob, ordered chan<- drawJob) {
 	xyMovements := []float64{}
 	for d := 0.0; d <= ImageSize/2.0; d += 2.0 {
 		xyMovements = append(xyMovements, d)
@@ -81,6 +143,7 @@ func prepareMeta(drawers chan<- Drawer) {
 		&emptyDrawer{Samples: 1000, Noise: 0.015},
 		&incompleteEdgeDrawer{Movements: xyMovements, Angles: dAngle},
 	}
+	dr = append(dr, extra...)
 
 	size := 0
 	for _, d := range dr {
@@ -89,22 +152,39 @@ func prepareMeta(drawers chan<- Drawer) {
 	progress = pb.StartNew(size)
 
 	for _, d := range dr {
-		drawers <- d
+		job := drawJob{
+			drawer: d,
+			rng:    rand.New(rand.NewSource(rng.Int63())),
+			images: make(chan Image, 100),
+		}
+		ordered <- job
+		jobs <- job
 	}
 }
 
-func drawWithDrawer(drawers <-chan Drawer, images chan<- Image) {
-	for {
-		drawer, ok := <-drawers
-		if !ok {
-			break
-		}
+func drawWithDrawer(jobs <-chan drawJob) {
+	for job := range jobs {
+		job.drawer.Draw(job.rng, job.images)
+		close(job.images)
+	}
+}
 
-		drawer.Draw(images)
+// mergeImages passes images of jobs one job after another, in order jobs were prepared.
+// Images are numbered and get seeds of their own random numbers generators.
+func mergeImages(ordered <-chan drawJob, rng *rand.Rand, images chan<- Image) {
+	seq := 0
+	for job := range ordered {
+		for img := range job.images {
+			img.Seq = seq
+			img.Seed = rng.Int63()
+			images <- img
+			seq++
+		}
 	}
 }
 
-func imgCouter(images <-chan Image, counters chan<- Counter) {
+// imgCouter numbers images and passes them to all savers
+func imgCouter(images <-chan Image, savers ...chan<- Counter) {
 	cnt := 1
 	for img := range images {
 		if img.OffCenter > imageCutOff {
@@ -112,25 +192,59 @@ func imgCouter(images <-chan Image, counters chan<- Counter) {
 			img.GridInfo.FragmentSuper = FragmentSuperTypeEmpty
 		}
 
-		counters <- Counter{
+		counter := Counter{
 			Image: img,
 			ID:    cnt,
 		}
+		for _, saver := range savers {
+			saver <- counter
+		}
+		progress.Increment()
 		cnt++
 	}
 }
 
-func imgSaver(counters <-chan Counter) {
+// imgSaver writes images as PNG files, each fragment type in its own directory
+func imgSaver(outDir string, counters <-chan Counter) {
 	for counter := range counters {
+		labelDir := path.Join(outDir, counter.Fragment.String())
+		if err := os.MkdirAll(labelDir, 0764); err != nil {
+			fmt.Println(err)
+			continue
+		}
+
 		fileName := fmt.Sprintf("fragment-%06d-%v.png", counter.ID, counter.Fragment)
-		if err := draw2dimg.SaveToPngFile(path.Join(outDir, fileName), counter.Image.Image); err != nil {
+		if err := draw2dimg.SaveToPngFile(path.Join(labelDir, fileName), counter.Image.Image); err != nil {
 			fmt.Println(err)
 		}
-		progress.Increment()
 	}
 }
 
-func gobSaver(trainFile string, testFile string, counters <-chan Counter) {
+// header describes data files of fragments generated with params
+func header(params map[string]string) dataset.Header {
+	return dataset.Header{
+		Kind:   dataset.KindGrid,
+		Width:  ImageSize,
+		Height: ImageSize,
+		Labels: Labels(),
+		Params: params,
+	}
+}
+
+// params returns options fragments were generated with, stored in header of data files
+func (o Options) params() map[string]string {
+	params := map[string]string{"seed": fmt.Sprint(o.Seed)}
+	if o.Degradation != nil {
+		params["degradation"] = fmt.Sprintf("%+v", *o.Degradation)
+	}
+	if o.PagesDir != "" {
+		params["pages"] = o.PagesDir
+		params["page_shifts"] = fmt.Sprint(o.PageShifts)
+	}
+	return params
+}
+
+func gobSaver(trainFile string, testFile string, header dataset.Header, counters <-chan Counter) {
 	csvFileTrain, err := os.Create(trainFile)
 	if err != nil {
 		panic(err)
@@ -143,49 +257,167 @@ func gobSaver(trainFile string, testFile string, counters <-chan Counter) {
 	}
 	defer csvFileTest.Close()
 
-	train := gob.NewEncoder(csvFileTrain)
-	test := gob.NewEncoder(csvFileTest)
+	train, err := dataset.NewWriter(csvFileTrain, header)
+	if err != nil {
+		panic(err)
+	}
+	test, err := dataset.NewWriter(csvFileTest, header)
+	if err != nil {
+		panic(err)
+	}
 
 	for counter := range counters {
-		bounds := counter.Image.Image.Bounds()
 		record := Record{
+			Pic:           ImageToPic(counter.Image.Image),
 			Fragment:      counter.GridInfo.Fragment,
 			FragmentSuper: counter.GridInfo.FragmentSuper,
 		}
 
-		pos := 0
-		for x := 0; x < bounds.Max.X; x++ {
-			for y := 0; y < bounds.Max.Y; y++ {
-				clr := counter.Image.Image.At(x, y)
-				grayColor := color.GrayModel.Convert(clr).(color.Gray)
-				record.Pic[pos] = grayColor.Y
-				pos++
-			}
-		}
 		if counter.GridInfo.Train {
-			train.Encode(record)
+			err = train.Encode(record)
 		} else {
-			test.Encode(record)
+			err = test.Encode(record)
 		}
-		progress.Increment()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := train.Close(); err != nil {
+		panic(err)
+	}
+	if err := test.Close(); err != nil {
+		panic(err)
 	}
 }
 
-func GenerateSudokuGrid() error {
-	os.RemoveAll(outDir)
-	if err := os.Mkdir(outDir, 0764); err != nil {
+// ImageToPic converts image to pixels in the same layout as stored in Record
+func ImageToPic(img image.Image) (pic [ImageSize * ImageSize]uint8) {
+	dataset.ImageToPic(img, pic[:], ImageSize)
+	return
+}
+
+func GenerateSudokuGrid(options Options) error {
+	options.setDefaults()
+
+	var extra []Drawer
+	if options.PagesDir != "" {
+		pages, err := newPageDrawer(options.PagesDir, options.PageShifts)
+		if err != nil {
+			return err
+		}
+		extra = append(extra, pages)
+	}
+
+	if err := dataset.PrepareOutDir(options.OutDir, options.Force); err != nil {
 		return err
 	}
 
-	drawers := make(chan Drawer, 100)
+	rng := rand.New(rand.NewSource(options.Seed))
+	imagesRng := rand.New(rand.NewSource(rng.Int63()))
+
+	jobs := make(chan drawJob, 100)
+	ordered := make(chan drawJob, 100)
 	images := make(chan Image, 100)
 	counters := make(chan Counter, 100)
+	pngCounters := make(chan Counter, 100)
 
-	common.RoutineRunner(1, true, func() { prepareMeta(drawers) }, func() { close(drawers) })
-	common.RoutineRunner(4, true, func() { drawWithDrawer(drawers, images) }, func() { close(images) })
-	common.RoutineRunner(1, true, func() { imgCouter(images, counters) }, func() { close(counters) })
-	// common.RoutineRunner(4, false, func() { imgSaver(counters) }, nil)
-	common.RoutineRunner(1, false, func() { gobSaver(TrainFile, TestFile, counters) }, nil)
+	var savers []chan<- Counter
+	wgSavers := sync.WaitGroup{}
+	if !options.PNGOnly {
+		savers = append(savers, counters)
+		wgSavers.Add(1)
+		common.RoutineRunner(1, true, func() { gobSaver(options.TrainFile, options.TestFile, header(options.params()), counters) }, wgSavers.Done)
+	}
+	if options.PNG {
+		savers = append(savers, pngCounters)
+		wgSavers.Add(1)
+		common.RoutineRunner(4, true, func() { imgSaver(path.Join(options.OutDir, PNGDirName), pngCounters) }, wgSavers.Done)
+	}
+
+	common.RoutineRunner(1, true, func() { prepareMeta(rng, extra, jobs, ordered) }, func() { close(jobs); close(ordered) })
+	common.RoutineRunner(4, true, func() { drawWithDrawer(jobs) }, nil)
+	common.RoutineRunner(1, true, func() { mergeImages(ordered, imagesRng, images) }, func() { close(images) })
+	ready := images
+	if options.Degradation != nil {
+		degraded := make(chan Image, 100)
+		ready = make(chan Image, 100)
+		common.RoutineRunner(4, true, func() { degrade(*options.Degradation, images, degraded) }, func() { close(degraded) })
+		common.RoutineRunner(1, true, func() {
+			// Images which could not be degraded are dropped
+			common.Reorder(func() (int, interface{}, bool) {
+				img, ok := <-degraded
+				return img.Seq, img, ok
+			}, func(item interface{}) {
+				if img := item.(Image); img.Image != nil {
+					ready <- img
+				}
+			})
+		}, func() { close(ready) })
+	}
+	common.RoutineRunner(1, true, func() { imgCouter(ready, savers...) }, func() {
+		close(counters)
+		close(pngCounters)
+	})
+	wgSavers.Wait()
+	progress.Finish()
 
 	return nil
 }
+
+// Migrate converts data file written by older version of generator to current version
+func Migrate(fileName string) error {
+	return dataset.Rewrite(fileName, func(r io.Reader, w io.Writer) error {
+		records, err := dataset.NewReader(r, dataset.KindGrid, ImageSize)
+		if err != nil {
+			return err
+		}
+		if records.Header.Version == dataset.Version {
+			return dataset.ErrUpToDate
+		}
+
+		out, err := dataset.NewWriter(w, header(nil))
+		if err != nil {
+			return err
+		}
+		for {
+			record := Record{}
+			err := records.Decode(&record)
+			if err == io.EOF {
+				return out.Close()
+			} else if err != nil {
+				return err
+			}
+
+			dataset.FixLayout(records.Header.Version, record.Pic[:], ImageSize)
+			if err := out.Encode(record); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Preview renders contact sheet of data file with row of up to samples random images per fragment type
+func Preview(fileName string, samples int, rng *rand.Rand) (image.Image, error) {
+	records, err := dataset.Open(fileName, dataset.KindGrid, ImageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer records.Close()
+
+	sampler := dataset.NewSampler(samples, rng)
+	for {
+		record := Record{}
+		err := records.Decode(&record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		dataset.FixLayout(records.Header.Version, record.Pic[:], ImageSize)
+		sampler.Add(record.Fragment.String(), record.Pic[:])
+	}
+
+	return dataset.ContactSheet(sampler.Rows(), ImageSize), nil
+}
@@ -0,0 +1,43 @@
+package gridgen
+
+import (
+	"fmt"
+
+	"github.com/mrfuxi/digit/dataset"
+)
+
+// ExportIDX writes records of data file as gzip compressed IDX files of images and labels.
+// Labels are fragment types, see Labels.
+func ExportIDX(fileName, imagesFile, labelsFile string) ([]string, error) {
+	return dataset.ExportIDX(fileName, dataset.KindGrid, ImageSize, imagesFile, labelsFile, func(r *dataset.Reader) ([]uint8, int, error) {
+		record := Record{}
+		if err := r.Decode(&record); err != nil {
+			return nil, 0, err
+		}
+		return record.Pic[:], int(record.Fragment), nil
+	})
+}
+
+// ImportIDX writes images of IDX files as data file. Labels are names of fragment types
+// in label map, which may be ordered differently than Labels.
+func ImportIDX(imagesFile, labelsFile, fileName string, labels []string, transpose bool) error {
+	types := map[string]FragmentType{}
+	for _, fragment := range FragmentTypes {
+		types[fragment.String()] = fragment
+	}
+	fragments := make([]FragmentType, len(labels))
+	for i, label := range labels {
+		fragment, ok := types[label]
+		if !ok {
+			return fmt.Errorf("Unknown fragment type %q", label)
+		}
+		fragments[i] = fragment
+	}
+
+	header := header(map[string]string{"source": imagesFile})
+	return dataset.ImportIDX(imagesFile, labelsFile, fileName, len(labels), header, transpose, func(pic []uint8, label int) interface{} {
+		record := Record{Fragment: fragments[label], FragmentSuper: FragmentTypeToSuper(fragments[label])}
+		copy(record.Pic[:], pic)
+		return record
+	})
+}
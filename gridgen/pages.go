@@ -0,0 +1,186 @@
+package gridgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/pagegen"
+	"github.com/mrfuxi/digit/solver"
+)
+
+var ErrNoPages = errors.New("No annotated pages found")
+var ErrAnnotation = errors.New("Annotation needs 4 corners or all intersections of grid")
+
+// lattice is number of intersections in row and column of sudoku grid
+const lattice = solver.Size + 1
+
+// pageDrawer cuts fragments around intersections of grid lines out of annotated page images,
+// like those written by pagegen. Each page image has JSON annotation with the same base name.
+type pageDrawer struct {
+	// Pages with light lines on dark paper
+	Pages []*image.Gray
+	// Intersections of grid lines of each page, row by row
+	Points [][]common.Point
+	// Number of randomly moved crops taken around each intersection
+	Shifts int
+}
+
+// newPageDrawer loads PNG and JPEG images with annotations in directory.
+// Pages which can not be read or have incomplete annotation are reported as error.
+func newPageDrawer(dir string, shifts int) (*pageDrawer, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pageDrawer{Shifts: shifts}
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+
+		name := filepath.Join(dir, file.Name())
+		content, err := ioutil.ReadFile(strings.TrimSuffix(name, filepath.Ext(name)) + ".json")
+		if err != nil {
+			continue
+		}
+		annotation := pagegen.Annotation{}
+		if err := json.Unmarshal(content, &annotation); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		points, err := intersections(annotation)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		img, err := common.LoadImage(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+
+		p.Pages = append(p.Pages, common.LightOnDark(common.ToGray(img)))
+		p.Points = append(p.Points, points)
+	}
+
+	if len(p.Pages) == 0 {
+		return nil, ErrNoPages
+	}
+	return p, nil
+}
+
+// Draw crops fragments of each page. All fragments of page go either to train or test data,
+// so overlapping crops of the same grid do not leak between them.
+func (p *pageDrawer) Draw(rng *rand.Rand, images chan<- Image) {
+	for i, page := range p.Pages {
+		points := p.Points[i]
+		train := rng.Intn(100) >= 5
+		for row := 0; row < lattice; row++ {
+			for col := 0; col < lattice; col++ {
+				fragment := LatticeFragment(col, row, solver.Size)
+				center := points[row*lattice+col]
+				scale := cellSize(points, col, row) / ImageSize
+
+				for shift := 0; shift <= p.Shifts; shift++ {
+					dx, dy := 0.0, 0.0
+					if shift > 0 {
+						dx = (rng.Float64() - 0.5) * ImageSize
+						dy = (rng.Float64() - 0.5) * ImageSize
+					}
+					moved := common.Point{X: center.X + dx*scale, Y: center.Y + dy*scale}
+
+					images <- Image{
+						GridInfo: GridInfo{
+							Fragment:      fragment,
+							FragmentSuper: FragmentTypeToSuper(fragment),
+							Train:         train,
+						},
+						Image:     crop(page, moved, scale),
+						OffCenter: math.Max(math.Abs(dx), math.Abs(dy)),
+					}
+				}
+			}
+		}
+	}
+}
+
+func (p *pageDrawer) Count() int {
+	return len(p.Pages) * lattice * lattice * (1 + p.Shifts)
+}
+
+// intersections returns points where grid lines cross on page, row by row.
+// Annotations with only outer corners are filled in assuming flat grid seen in perspective.
+func intersections(annotation pagegen.Annotation) ([]common.Point, error) {
+	points := make([]common.Point, 0, lattice*lattice)
+	switch len(annotation.Intersections) {
+	case lattice * lattice:
+		for _, p := range annotation.Intersections {
+			points = append(points, common.Point{X: p[0], Y: p[1]})
+		}
+		return points, nil
+	case 0:
+	default:
+		return nil, ErrAnnotation
+	}
+	if annotation.Corners == [4][2]float64{} {
+		return nil, ErrAnnotation
+	}
+
+	corners := make([]common.Point, len(annotation.Corners))
+	for i, c := range annotation.Corners {
+		corners[i] = common.Point{X: c[0], Y: c[1]}
+	}
+	h, err := common.FitHomography(
+		[]common.Point{{X: 0, Y: 0}, {X: solver.Size, Y: 0}, {X: solver.Size, Y: solver.Size}, {X: 0, Y: solver.Size}},
+		corners,
+	)
+	if err != nil {
+		return nil, ErrAnnotation
+	}
+	for row := 0; row < lattice; row++ {
+		for col := 0; col < lattice; col++ {
+			points = append(points, h.Apply(common.Point{X: float64(col), Y: float64(row)}))
+		}
+	}
+	return points, nil
+}
+
+// cellSize returns average distance from intersection to its neighbours
+func cellSize(points []common.Point, col, row int) float64 {
+	center := points[row*lattice+col]
+	sum, count := 0.0, 0
+	for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		c, r := col+d[0], row+d[1]
+		if c < 0 || r < 0 || c >= lattice || r >= lattice {
+			continue
+		}
+		sum += center.Dist(points[r*lattice+c])
+		count++
+	}
+	return sum / float64(count)
+}
+
+// crop cuts fragment centered at point, scale is size of fragment pixel in page pixels.
+// Fragment is not straightened, so it looks like window of sudoku reader.
+func crop(page *image.Gray, center common.Point, scale float64) *image.Gray {
+	fragment := image.NewGray(image.Rect(0, 0, ImageSize, ImageSize))
+	half := ImageSize / 2.0
+	for v := 0; v < ImageSize; v++ {
+		for u := 0; u < ImageSize; u++ {
+			point := common.Point{
+				X: center.X + (float64(u)+0.5-half)*scale,
+				Y: center.Y + (float64(v)+0.5-half)*scale,
+			}
+			fragment.SetGray(u, v, color.Gray{Y: common.BilinearGray(page, point)})
+		}
+	}
+	return fragment
+}
@@ -0,0 +1,126 @@
+package gridgen
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mrfuxi/digit/pagegen"
+)
+
+// writePage writes page with dark grid on white paper and annotation with its corners only
+func writePage(t *testing.T, dir string) {
+	const first, cell = 20, 40
+	last := first + 9*cell
+
+	page := image.NewGray(image.Rect(0, 0, 400, 400))
+	for i := range page.Pix {
+		page.Pix[i] = 255
+	}
+	for line := first; line <= last; line += cell {
+		for pos := first; pos <= last; pos++ {
+			for w := -1; w <= 1; w++ {
+				page.Pix[page.PixOffset(line+w, pos)] = 0
+				page.Pix[page.PixOffset(pos, line+w)] = 0
+			}
+		}
+	}
+
+	file, err := os.Create(filepath.Join(dir, "page.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, page); err != nil {
+		t.Fatal(err)
+	}
+
+	end := float64(last)
+	annotation := pagegen.Annotation{Corners: [4][2]float64{{first, first}, {end, first}, {end, end}, {first, end}}}
+	content, _ := json.Marshal(annotation)
+	if err := ioutil.WriteFile(filepath.Join(dir, "page.json"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPageDrawer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := newPageDrawer(dir, 1); err != ErrNoPages {
+		t.Errorf("Expected %v, got %v", ErrNoPages, err)
+	}
+
+	writePage(t, dir)
+	drawer, err := newPageDrawer(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := filepath.Join(dir, "broken")
+	if err := os.Mkdir(broken, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writePage(t, broken)
+	ioutil.WriteFile(filepath.Join(broken, "page.json"), []byte(`{"intersections": [[1, 2]]}`), 0644)
+	if _, err := newPageDrawer(broken, 1); err == nil || !strings.HasSuffix(err.Error(), ErrAnnotation.Error()) {
+		t.Errorf("Expected incomplete annotation to be reported, got %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(broken, "page.json"), []byte(`{}`), 0644)
+	if _, err := newPageDrawer(broken, 1); err == nil || !strings.HasSuffix(err.Error(), ErrAnnotation.Error()) {
+		t.Errorf("Expected annotation without corners to be reported, got %v", err)
+	}
+	writePage(t, broken)
+	ioutil.WriteFile(filepath.Join(broken, "page.png"), []byte("not an image"), 0644)
+	if _, err := newPageDrawer(broken, 1); err == nil {
+		t.Error("Expected unreadable page to be reported")
+	}
+
+	images := make(chan Image, drawer.Count())
+	drawer.Draw(rand.New(rand.NewSource(1)), images)
+	close(images)
+	if len(images) != drawer.Count() || drawer.Count() != 200 {
+		t.Fatalf("Expected 200 fragments, got %v (count %v)", len(images), drawer.Count())
+	}
+
+	counts := map[FragmentType]int{}
+	trains := map[bool]int{}
+	for img := range images {
+		trains[img.Train]++
+		if img.OffCenter != 0 {
+			continue
+		}
+		counts[img.Fragment]++
+
+		// Lines are light after inverting page and cross in the middle of fragment
+		gray := img.Image.(*image.Gray)
+		if center := gray.GrayAt(ImageSize/2, ImageSize/2).Y; center < 128 {
+			t.Errorf("Expected light center of %v, got %v", img.Fragment, center)
+		}
+	}
+
+	// Fragments of single page all go to the same data
+	if len(trains) != 1 {
+		t.Errorf("Expected all fragments of page in the same data, got %v", trains)
+	}
+
+	expected := map[FragmentType]int{
+		FragmentTypeCornerNW: 1, FragmentTypeCornerNE: 1, FragmentTypeCornerSE: 1, FragmentTypeCornerSW: 1,
+		FragmentTypeEdgeN: 8, FragmentTypeEdgeE: 8, FragmentTypeEdgeS: 8, FragmentTypeEdgeW: 8,
+		FragmentTypeCross: 64,
+	}
+	for fragment, count := range expected {
+		if counts[fragment] != count {
+			t.Errorf("Expected %v centered %v fragments, got %v", count, fragment, counts[fragment])
+		}
+	}
+}
@@ -1,149 +1,243 @@
 package gridnet
 
 import (
-	"encoding/gob"
+	"errors"
 	"fmt"
+	"image"
 	"io"
-	"math/rand"
-	"os"
 	"time"
 
 	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
 	"github.com/mrfuxi/digit/gridgen"
 	"github.com/mrfuxi/neural"
 )
 
+const inputSize = gridgen.ImageSize * gridgen.ImageSize
+
+// Targets of training: what outputs of network mean
 const (
-	inputSize = gridgen.ImageSize * gridgen.ImageSize
-	// outputSize = 4
-	outputSize = 10
+	TargetFragment = "fragment" // Fragment types
+	TargetSuper    = "super"    // Fragment super types
 )
 
-func prepareGridData(r io.Reader) (examples []neural.TrainExample) {
-	dec := gob.NewDecoder(r)
+var ErrTarget = errors.New("Unknown target, expected fragment or super")
 
-	for {
-		tmp := gridgen.Record{}
-		err := dec.Decode(&tmp)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic(err)
-		}
-		image := tmp.Pic
-		label := tmp.Fragment
-		if gridgen.IsEmpty(label) {
-			label = gridgen.FragmentTypeEmpty
-		}
-		// label := tmp.FragmentSuper
+// OutputSize returns number of network outputs for target
+func OutputSize(target string) (int, error) {
+	switch target {
+	case TargetFragment, "":
+		return len(gridgen.FragmentTypes), nil
+	case TargetSuper:
+		return len(gridgen.FragmentSuperTypes), nil
+	}
+	return 0, ErrTarget
+}
 
-		example := neural.TrainExample{
-			Input:  make([]float64, inputSize, inputSize),
-			Output: make([]float64, outputSize, outputSize),
-		}
+// SpecTarget returns target network was trained on. Networks saved without target were trained on fragment types.
+func SpecTarget(spec common.ModelSpec) string {
+	if spec.Target == "" {
+		return TargetFragment
+	}
+	return spec.Target
+}
 
-		for j, pix := range image {
-			example.Input[j] = (float64(pix)/255)*0.9 + 0.1
-		}
+// emptyLabel returns index of output of empty fragments, noise is added to them by randomized trainer
+func emptyLabel(target string) int {
+	if target == TargetSuper {
+		return int(gridgen.FragmentSuperTypeEmpty)
+	}
+	return int(gridgen.FragmentTypeEmpty)
+}
 
-		for j := range example.Output {
-			example.Output[j] = 0
-		}
-		example.Output[label] = 1
-		examples = append(examples, example)
+// label returns index of expected output for record
+func label(record gridgen.Record, target string) int {
+	if target == TargetSuper {
+		return int(record.FragmentSuper)
 	}
-	return
+	return int(record.Fragment)
 }
 
-func loadTrainData() ([]neural.TrainExample, []neural.TrainExample) {
-	trainFile, err := os.Open(gridgen.TrainFile)
+// recordReader reads samples of grid data file, labeled for target
+type recordReader struct {
+	records *dataset.Reader
+	target  string
+}
+
+func openRecords(fileName, target string) (common.SampleReader, error) {
+	records, err := dataset.Open(fileName, dataset.KindGrid, gridgen.ImageSize)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	return &recordReader{records: records, target: target}, nil
+}
+
+// Read returns next sample, with its label as stratum used when data is split
+func (r *recordReader) Read() (common.Sample, error) {
+	tmp := gridgen.Record{}
+	if err := r.records.Decode(&tmp); err != nil {
+		return common.Sample{}, err
 	}
-	defer trainFile.Close()
-	tmp := prepareGridData(trainFile)
-	trainData := tmp[:len(tmp)-5]
-	validationData := tmp[len(tmp)-5:]
-	return trainData, validationData
+	dataset.FixLayout(r.records.Header.Version, tmp.Pic[:], gridgen.ImageSize)
+	label := label(tmp, r.target)
+	return common.Sample{Pic: tmp.Pic[:], Label: label, Stratum: fmt.Sprint(label)}, nil
+}
+
+func (r *recordReader) Close() error {
+	return r.records.Close()
 }
 
-func loadTestData() []neural.TrainExample {
-	testFile, err := os.Open(gridgen.TestFile)
+// countRecords returns number of records stored in header of data file, dataset.CountUnknown for legacy files
+func countRecords(fileName string) (int, error) {
+	header, err := dataset.ReadFileHeader(fileName)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	defer testFile.Close()
+	return header.Count, header.Check(dataset.KindGrid, gridgen.ImageSize)
+}
 
-	testData := prepareGridData(testFile)
-	return testData
+// Data returns dataset of grid data files for network trained on target
+func Data(target string) (common.Dataset, error) {
+	outputSize, err := OutputSize(target)
+	if err != nil {
+		return common.Dataset{}, err
+	}
+	return common.Dataset{
+		Open: func(fileName string) (common.SampleReader, error) {
+			return openRecords(fileName, target)
+		},
+		Count:   countRecords,
+		Encode:  encodePic,
+		Inputs:  inputSize,
+		Outputs: outputSize,
+	}, nil
 }
 
-func BuildNN() neural.Evaluator {
-	activator := neural.NewSigmoidActivator()
-	outActivator := neural.NewSoftmaxActivator()
-	// outActivator := neural.NewSigmoidActivator()
-	nn := neural.NewNeuralNetwork(
-		[]int{inputSize, 20, outputSize},
-		neural.NewFullyConnectedLayer(activator),
-		neural.NewFullyConnectedLayer(outActivator),
-	)
-	return nn
+func encodePic(dst []float64, pic []uint8) {
+	for j, pix := range pic {
+		dst[j] = (float64(pix)/255)*0.9 + 0.1
+	}
 }
 
-func RunTraining(nn neural.Evaluator) {
-	fmt.Println("Loading train data")
-	testData := loadTestData()
-	trainData, validationData := loadTrainData()
+// ImageInput converts image of any size to network input.
+// Image is expected to have light lines on dark background.
+func ImageInput(img image.Image) []float64 {
+	if bounds := img.Bounds(); bounds.Dx() != gridgen.ImageSize || bounds.Dy() != gridgen.ImageSize {
+		img = common.Resize(img, gridgen.ImageSize, gridgen.ImageSize)
+	}
+	pic := gridgen.ImageToPic(img)
+
+	input := make([]float64, inputSize, inputSize)
+	encodePic(input, pic[:])
+	return input
+}
 
-	cost := neural.NewCrossEntropyCost()
-	// cost := neural.NewLogLikelihoodCost()
-	options := neural.TrainOptions{
+// DefaultSpec returns architecture of network used when none is given
+func DefaultSpec() common.ModelSpec {
+	spec, _ := TargetSpec(TargetFragment)
+	return spec
+}
+
+// TargetSpec returns default architecture of network trained on target
+func TargetSpec(target string) (common.ModelSpec, error) {
+	outputSize, err := OutputSize(target)
+	if err != nil {
+		return common.ModelSpec{}, err
+	}
+	return common.ModelSpec{
+		Input:            inputSize,
+		Hidden:           []int{20},
+		Activations:      []string{common.ActivationSigmoid},
+		Output:           outputSize,
+		OutputActivation: common.ActivationSoftmax,
+		Target:           target,
+	}, nil
+}
+
+// DefaultTraining returns hyperparameters used when none are given
+func DefaultTraining() common.TrainingSpec {
+	return common.TrainingSpec{
 		Epochs:         5,
 		MiniBatchSize:  10,
 		LearningRate:   0.01,
 		Regularization: 2,
 		Momentum:       0.9,
-		TrainerFactory: neural.NewBackpropagationTrainer,
-		EpocheCallback: common.EpocheCallback(nn, cost, validationData, testData),
-		Cost:           cost,
+		Cost:           common.CostCrossEntropy,
+		Trainer:        common.TrainerBackprop,
+		Noise:          common.DefaultNoise,
+		Validation:     0.1,
+	}
+}
+
+// RunTraining trains network of spec using data from trainFile, reporting progress on test data from testFile
+func RunTraining(nn neural.Evaluator, spec common.ModelSpec, trainFile, testFile string) error {
+	target := SpecTarget(spec)
+	training := DefaultTraining()
+	if spec.Training != nil {
+		training = *spec.Training
+	}
+	options, err := training.TrainOptions(emptyLabel(target))
+	if err != nil {
+		return err
+	}
+
+	data, err := Data(target)
+	if err != nil {
+		return err
 	}
 
 	fmt.Println("Start training")
 
 	t0 := time.Now()
-	neural.Train(nn, trainData, options)
+	if err := common.TrainNN(nn, spec, training, options, data, trainFile, testFile); err != nil {
+		return err
+	}
 	dt := time.Since(t0)
 
 	fmt.Println("Training complete in", dt)
+	return nil
 }
 
-type randTrainer struct {
-	BaseTrainer neural.Trainer
-	Sample      neural.TrainExample
-}
-
-func NewRandomizedTrainer(network neural.Evaluator, cost neural.CostDerivative) neural.Trainer {
-	t := &randTrainer{
-		BaseTrainer: neural.NewBackpropagationTrainer(network, cost),
-		Sample: neural.TrainExample{
-			Input:  make([]float64, inputSize, inputSize),
-			Output: make([]float64, outputSize, outputSize),
-		},
+// Evaluate runs network trained on target over data file and returns confusion matrices of fragment types
+// and fragment super types. Network trained on super types has no confusion matrix of fragment types.
+func Evaluate(nn neural.Evaluator, target string, fileName string) (*common.Confusion, *common.Confusion, error) {
+	records, err := openRecords(fileName, target)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer records.Close()
 
-	return t
-}
+	var labels, superLabels []string
+	for _, fragment := range gridgen.FragmentTypes {
+		labels = append(labels, fragment.String())
+	}
+	for _, super := range gridgen.FragmentSuperTypes {
+		superLabels = append(superLabels, super.String())
+	}
 
-func (r *randTrainer) Process(sample neural.TrainExample, weightUpdates *neural.WeightUpdates) {
-	copy(r.Sample.Input, sample.Input)
-	copy(r.Sample.Output, sample.Output)
+	superConfusion := common.NewConfusion(superLabels)
+	var confusion *common.Confusion
+	if target != TargetSuper {
+		confusion = common.NewConfusion(labels)
+	}
+	input := make([]float64, inputSize)
+	for {
+		sample, err := records.Read()
+		if err == io.EOF {
+			return confusion, superConfusion, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
 
-	// Empty
-	if r.Sample.Output[0] == 1 {
-		for i := 0; i < inputSize/10; i++ {
-			r.Sample.Input[rand.Intn(inputSize)] = 1
+		encodePic(input, sample.Pic)
+		predicted := common.Argmax(nn.Evaluate(input))
+		if target == TargetSuper {
+			superConfusion.Add(sample.Label, predicted)
+			continue
 		}
-	}
 
-	r.BaseTrainer.Process(r.Sample, weightUpdates)
+		actual := gridgen.FragmentType(sample.Label)
+		confusion.Add(int(actual), predicted)
+		superConfusion.Add(int(gridgen.FragmentTypeToSuper(actual)), int(gridgen.FragmentTypeToSuper(gridgen.FragmentType(predicted))))
+	}
 }
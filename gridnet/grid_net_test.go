@@ -0,0 +1,150 @@
+package gridnet
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
+	"github.com/mrfuxi/digit/gridgen"
+	"github.com/mrfuxi/neural"
+)
+
+// dataFile writes grid data file with record of each fragment type and returns its name
+func dataFile(t *testing.T, fragments ...gridgen.FragmentType) string {
+	dir, err := ioutil.TempDir("", "gridnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := path.Join(dir, "grid.data")
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	header := dataset.Header{Kind: dataset.KindGrid, Width: gridgen.ImageSize, Height: gridgen.ImageSize}
+	w, err := dataset.NewWriter(file, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range fragments {
+		record := gridgen.Record{Fragment: fragment, FragmentSuper: gridgen.FragmentTypeToSuper(fragment)}
+		if err := w.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+type fixedEvaluator struct {
+	neural.Evaluator
+	output []float64
+}
+
+func (f *fixedEvaluator) Evaluate(input []float64) []float64 {
+	return f.output
+}
+
+func TestOutputSize(t *testing.T) {
+	if size, err := OutputSize(""); err != nil || size != len(gridgen.FragmentTypes) {
+		t.Errorf("Expected fragment outputs without target, got %v (%v)", size, err)
+	}
+	if size, err := OutputSize(TargetSuper); err != nil || size != len(gridgen.FragmentSuperTypes) {
+		t.Errorf("Expected super type outputs, got %v (%v)", size, err)
+	}
+	if _, err := OutputSize("unknown"); err != ErrTarget {
+		t.Errorf("Expected ErrTarget, got %v", err)
+	}
+}
+
+func TestSpecTarget(t *testing.T) {
+	if target := SpecTarget(common.ModelSpec{}); target != TargetFragment {
+		t.Errorf("Expected network saved without target to be trained on fragments, got %q", target)
+	}
+	if target := SpecTarget(common.ModelSpec{Target: TargetSuper}); target != TargetSuper {
+		t.Errorf("Expected stored target, got %q", target)
+	}
+}
+
+func TestEmptyLabel(t *testing.T) {
+	if label := emptyLabel(TargetFragment); label != int(gridgen.FragmentTypeEmpty) {
+		t.Errorf("Expected empty fragment type, got %v", label)
+	}
+	if label := emptyLabel(TargetSuper); label != int(gridgen.FragmentSuperTypeEmpty) {
+		t.Errorf("Expected empty super type, got %v", label)
+	}
+}
+
+func TestData(t *testing.T) {
+	fileName := dataFile(t, gridgen.FragmentTypeCornerNE, gridgen.FragmentTypeCross)
+	defer os.RemoveAll(path.Dir(fileName))
+
+	for target, expected := range map[string][]int{
+		TargetFragment: {int(gridgen.FragmentTypeCornerNE), int(gridgen.FragmentTypeCross)},
+		TargetSuper:    {int(gridgen.FragmentSuperTypeCorner), int(gridgen.FragmentSuperTypeCross)},
+	} {
+		data, err := Data(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count, err := data.Count(fileName); err != nil || count != 2 {
+			t.Errorf("Expected 2 records, got %v (%v)", count, err)
+		}
+
+		samples, err := data.Load(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != len(expected) {
+			t.Fatalf("Expected %v samples for %v, got %v", len(expected), target, len(samples))
+		}
+		for i, sample := range samples {
+			if sample.Label != expected[i] || len(sample.Pic) != inputSize {
+				t.Errorf("Expected label %v of %v, got %v", expected[i], target, sample.Label)
+			}
+		}
+	}
+
+	if _, err := Data("unknown"); err != ErrTarget {
+		t.Errorf("Expected ErrTarget, got %v", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	fileName := dataFile(t, gridgen.FragmentTypeCornerNE, gridgen.FragmentTypeCornerSW, gridgen.FragmentTypeCross)
+	defer os.RemoveAll(path.Dir(fileName))
+
+	// Every fragment is taken for corner NE
+	output := make([]float64, len(gridgen.FragmentTypes))
+	output[gridgen.FragmentTypeCornerNE] = 1
+	confusion, superConfusion, err := Evaluate(&fixedEvaluator{output: output}, TargetFragment, fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confusion.Total() != 3 || confusion.Correct() != 1 {
+		t.Errorf("Expected 1 of 3 fragments to be correct, got %v of %v", confusion.Correct(), confusion.Total())
+	}
+	if superConfusion.Total() != 3 || superConfusion.Correct() != 2 {
+		t.Errorf("Expected 2 of 3 super types to be correct, got %v of %v", superConfusion.Correct(), superConfusion.Total())
+	}
+
+	superOutput := make([]float64, len(gridgen.FragmentSuperTypes))
+	superOutput[gridgen.FragmentSuperTypeCross] = 1
+	confusion, superConfusion, err = Evaluate(&fixedEvaluator{output: superOutput}, TargetSuper, fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confusion != nil {
+		t.Error("Expected no fragment confusion of network trained on super types")
+	}
+	if superConfusion.Total() != 3 || superConfusion.Correct() != 1 {
+		t.Errorf("Expected 1 of 3 super types to be correct, got %v of %v", superConfusion.Correct(), superConfusion.Total())
+	}
+}
@@ -2,17 +2,447 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"image"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
 	"github.com/mrfuxi/digit/digitgen"
 	"github.com/mrfuxi/digit/digitnet"
 	"github.com/mrfuxi/digit/gridgen"
 	"github.com/mrfuxi/digit/gridnet"
+	"github.com/mrfuxi/digit/pagegen"
+	"github.com/mrfuxi/digit/solver"
+	"github.com/mrfuxi/digit/sudoku"
 	"github.com/urfave/cli"
 )
 
 var errInputMissing = errors.New("Input file missing")
+var errImageMissing = errors.New("Image file missing")
+var errBrokenRules = errors.New("Board breaks sudoku rules")
+var errNoSolution = errors.New("Board has no solution")
+var errSpecMismatch = errors.New("Network input or output size does not match data")
+var errTargetMismatch = errors.New("Network was trained on different target")
+var errLabelMismatch = errors.New("Network labels do not match labels of data")
+var errGridTarget = errors.New("Reading sudoku needs grid network trained on fragment types")
+var errFormat = errors.New("Unknown format, expected idx")
+var errIDXMissing = errors.New("IDX files of images and labels missing")
+var errOutputMissing = errors.New("Output file missing")
+
+// evalGrid loads grid network and evaluates it on data file
+func evalGrid(fileName, data string) (common.ModelSpec, *common.Confusion, *common.Confusion, error) {
+	nn, spec, err := common.LoadNN(fileName, gridnet.DefaultSpec())
+	if err != nil {
+		return spec, nil, nil, err
+	}
+
+	target := gridnet.SpecTarget(spec)
+	outputs, err := gridnet.OutputSize(target)
+	if err != nil {
+		return spec, nil, nil, err
+	}
+	if err := checkSize(spec, gridnet.DefaultSpec().Input, outputs); err != nil {
+		return spec, nil, nil, err
+	}
+
+	confusion, superConfusion, err := gridnet.Evaluate(nn, target, data)
+	return spec, confusion, superConfusion, err
+}
+
+// checkSize returns errSpecMismatch if network does not have given number of inputs and outputs
+func checkSize(spec common.ModelSpec, inputs, outputs int) error {
+	if spec.Input != inputs || spec.Output != outputs {
+		return errSpecMismatch
+	}
+	return nil
+}
+
+func migrateFiles(fileNames []string, migrate func(fileName string) error) error {
+	if len(fileNames) == 0 {
+		return errInputMissing
+	}
+
+	for _, fileName := range fileNames {
+		err := migrate(fileName)
+		if err == dataset.ErrUpToDate {
+			fmt.Println(fileName, "already up to date")
+			continue
+		} else if err != nil {
+			return err
+		}
+		fmt.Println(fileName, "migrated")
+	}
+	return nil
+}
+
+// idxFileNames returns names of IDX files of images and labels, and of their label map, starting with prefix
+func idxFileNames(prefix string) (images, labels, labelMap string) {
+	return prefix + "-images-idx3-ubyte.gz", prefix + "-labels-idx1-ubyte.gz", prefix + "-labels.txt"
+}
+
+// exportFile writes data file given as argument in format given by flags
+func exportFile(c *cli.Context, export func(fileName, imagesFile, labelsFile string) ([]string, error)) error {
+	fileName := c.Args().First()
+	if fileName == "" {
+		return errInputMissing
+	}
+	if c.String("format") != "idx" {
+		return errFormat
+	}
+
+	prefix := c.String("output")
+	if prefix == "" {
+		prefix = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	imagesFile, labelsFile, labelMapFile := idxFileNames(prefix)
+	labels, err := export(fileName, imagesFile, labelsFile)
+	if err != nil {
+		return err
+	}
+	if err := dataset.WriteLabelMap(labelMapFile, labels); err != nil {
+		return err
+	}
+	fmt.Println("Exported to", imagesFile, labelsFile, labelMapFile)
+	return nil
+}
+
+// importFiles writes IDX files given by flags as data file, labeled with label map from flags or default one
+func importFiles(c *cli.Context, defaultLabels []string, importIDX func(imagesFile, labelsFile, fileName string, labels []string, transpose bool) error) error {
+	if c.String("format") != "idx" {
+		return errFormat
+	}
+	if c.String("images") == "" || c.String("labels") == "" {
+		return errIDXMissing
+	}
+	if c.String("output") == "" {
+		return errOutputMissing
+	}
+
+	labels := defaultLabels
+	if c.String("label-map") != "" {
+		var err error
+		if labels, err = dataset.ReadLabelMap(c.String("label-map")); err != nil {
+			return err
+		}
+	}
+	if err := importIDX(c.String("images"), c.String("labels"), c.String("output"), labels, c.Bool("transpose")); err != nil {
+		return err
+	}
+	fmt.Println("Imported to", c.String("output"))
+	return nil
+}
+
+// netSpec returns architecture of network given by flags on top of default one
+func netSpec(c *cli.Context, defaultSpec common.ModelSpec) (common.ModelSpec, error) {
+	spec := defaultSpec
+	if c.String("spec") != "" {
+		if err := common.LoadSpec(c.String("spec"), &spec); err != nil {
+			return spec, err
+		}
+	}
+
+	if c.IsSet("hidden") {
+		spec.Hidden = nil
+		for _, size := range splitList(c.String("hidden")) {
+			hidden, err := strconv.Atoi(size)
+			if err != nil {
+				return spec, err
+			}
+			spec.Hidden = append(spec.Hidden, hidden)
+		}
+	}
+	if c.IsSet("activation") {
+		spec.Activations = splitList(c.String("activation"))
+	}
+	if c.IsSet("output-activation") {
+		spec.OutputActivation = c.String("output-activation")
+	}
+
+	if spec.Input != defaultSpec.Input || spec.Output != defaultSpec.Output {
+		return spec, errSpecMismatch
+	}
+	return spec, spec.Validate()
+}
+
+// trainingSpec returns hyperparameters given by flags on top of ones from spec file or default ones
+func trainingSpec(c *cli.Context, spec common.ModelSpec, defaultTraining common.TrainingSpec) (common.TrainingSpec, error) {
+	training := defaultTraining
+	if spec.Training != nil {
+		training = *spec.Training
+	}
+
+	if c.IsSet("epochs") {
+		training.Epochs = c.Int("epochs")
+	}
+	if c.IsSet("batch") {
+		training.MiniBatchSize = c.Int("batch")
+	}
+	if c.IsSet("lr") {
+		training.LearningRate = c.Float64("lr")
+	}
+	if c.IsSet("l2") {
+		training.Regularization = c.Float64("l2")
+	}
+	if c.IsSet("momentum") {
+		training.Momentum = c.Float64("momentum")
+	}
+	if c.IsSet("cost") {
+		training.Cost = c.String("cost")
+	}
+	if c.IsSet("trainer") {
+		training.Trainer = c.String("trainer")
+	}
+	if c.IsSet("noise") {
+		training.Noise = c.Float64("noise")
+	}
+	if c.IsSet("validation") {
+		training.Validation = c.Float64("validation")
+	}
+	if c.IsSet("folds") {
+		training.Folds = c.Int("folds")
+	}
+	if c.IsSet("split-seed") {
+		training.Seed = c.Int64("split-seed")
+	}
+	if c.IsSet("chunk") {
+		training.Chunk = c.Int("chunk")
+	}
+	if c.IsSet("stream") {
+		training.Stream = c.Bool("stream")
+	}
+	if c.IsSet("shuffle-buffer") {
+		training.ShuffleBuffer = c.Int("shuffle-buffer")
+	}
+
+	return training, training.Validate()
+}
+
+// genSeed returns seed given by user or a new one. It is printed so data can be generated again.
+func genSeed(c *cli.Context) int64 {
+	seed := c.Int64("seed")
+	if !c.IsSet("seed") {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Println("Seed:", seed)
+	return seed
+}
+
+var seedFlag = cli.Int64Flag{
+	Name:  "seed",
+	Usage: "Seed of random numbers generator, same seed gives the same data (default: random)",
+}
+
+// genFlags returns flags of output paths shared by all generators
+func genFlags(outDir string) []cli.Flag {
+	return []cli.Flag{
+		seedFlag,
+		cli.StringFlag{
+			Name:  "out",
+			Value: outDir,
+			Usage: "Write generated data to `DIR`, it is removed first",
+		},
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Remove output directory even if it was not created by generator",
+		},
+		cli.StringFlag{
+			Name:  "train-file",
+			Usage: "Write train data to `FILE` (default: train file in output directory)",
+		},
+		cli.StringFlag{
+			Name:  "test-file",
+			Usage: "Write test data to `FILE` (default: test file in output directory)",
+		},
+		cli.BoolFlag{
+			Name:  "png",
+			Usage: "Save images as PNG files too, in per label directories of output directory",
+		},
+		cli.BoolFlag{
+			Name:  "png-only",
+			Usage: "Save images only as PNG files, without train and test data",
+		},
+	}
+}
+
+// dataFlags returns flags of data files used for training
+func dataFlags(trainFile, testFile string) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "train-file",
+			Value: trainFile,
+			Usage: "Train on data from `FILE`",
+		},
+		cli.StringFlag{
+			Name:  "test-file",
+			Value: testFile,
+			Usage: "Test on data from `FILE`",
+		},
+	}
+}
+
+var augmentFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "augment",
+		Usage: "Distort drawn digits with default augmentation, flags below override single distortions",
+	},
+	cli.StringFlag{
+		Name:  "rotation",
+		Usage: "Rotate by `P:MIN:MAX` degrees with probability P",
+	},
+	cli.StringFlag{
+		Name:  "shear",
+		Usage: "Shear by `P:MIN:MAX` pixels per pixel of height with probability P",
+	},
+	cli.StringFlag{
+		Name:  "elastic",
+		Usage: "Distort elastically moving pixels up to `P:MIN:MAX` pixels with probability P",
+	},
+	cli.StringFlag{
+		Name:  "stroke",
+		Usage: "Make stroke thicker (positive) or thinner (negative) by `P:MIN:MAX` pixels with probability P",
+	},
+	cli.StringFlag{
+		Name:  "blur",
+		Usage: "Blur with gaussian of `P:MIN:MAX` pixels with probability P",
+	},
+	cli.StringFlag{
+		Name:  "noise",
+		Usage: "Turn `P:MIN:MAX` fraction of pixels black or white with probability P",
+	},
+}
+
+// parseRanges overrides ranges with ones given in flags of the same names.
+// Returns true if any range was given or enable flag is set.
+func parseRanges(c *cli.Context, enable string, ranges map[string]*common.AugmentRange) (bool, error) {
+	enabled := c.Bool(enable)
+	for name, r := range ranges {
+		if !c.IsSet(name) {
+			continue
+		}
+		parsed, err := common.ParseAugmentRange(c.String(name))
+		if err != nil {
+			return false, fmt.Errorf("%v: %v", name, err)
+		}
+		*r = parsed
+		enabled = true
+	}
+	return enabled, nil
+}
+
+// parseAugmentation returns distortions of drawn digits or nil if none were asked for
+func parseAugmentation(c *cli.Context) (*digitgen.Augmentation, error) {
+	augmentation := digitgen.DefaultAugmentation()
+	enabled, err := parseRanges(c, "augment", map[string]*common.AugmentRange{
+		"rotation": &augmentation.Rotation,
+		"shear":    &augmentation.Shear,
+		"elastic":  &augmentation.Elastic,
+		"stroke":   &augmentation.Stroke,
+		"blur":     &augmentation.Blur,
+		"noise":    &augmentation.Noise,
+	})
+	if err != nil || !enabled {
+		return nil, err
+	}
+	fmt.Println("Augmentation:", augmentation)
+	return &augmentation, nil
+}
+
+var degradeFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "degrade",
+		Usage: "Distort fragments with default photographic degradation, flags below override single distortions",
+	},
+	cli.StringFlag{
+		Name:  "perspective",
+		Usage: "Warp perspective moving corners up to `P:MIN:MAX` pixels with probability P",
+	},
+	cli.StringFlag{
+		Name:  "border-width",
+		Usage: "Draw outer border of grid `P:MIN:MAX` pixels wide with probability P",
+	},
+	cli.StringFlag{
+		Name:  "line-width",
+		Usage: "Draw inner lines of grid `P:MIN:MAX` pixels wide with probability P",
+	},
+	cli.StringFlag{
+		Name:  "invert",
+		Usage: "Draw dark lines on paper of `P:MIN:MAX` brightness (0-1) with probability P",
+	},
+	cli.StringFlag{
+		Name:  "lighting",
+		Usage: "Darken one side of image by `P:MIN:MAX` fraction with probability P",
+	},
+	cli.StringFlag{
+		Name:  "blur",
+		Usage: "Blur with gaussian of `P:MIN:MAX` pixels with probability P",
+	},
+	cli.StringFlag{
+		Name:  "jpeg",
+		Usage: "Recompress as JPEG of `P:MIN:MAX` quality (1-100) with probability P",
+	},
+}
+
+// parseDegradation returns distortions of grid fragments or nil if none were asked for
+func parseDegradation(c *cli.Context) (*gridgen.Degradation, error) {
+	degradation := gridgen.DefaultDegradation()
+	enabled, err := parseRanges(c, "degrade", map[string]*common.AugmentRange{
+		"perspective":  &degradation.Perspective,
+		"border-width": &degradation.BorderWidth,
+		"line-width":   &degradation.LineWidth,
+		"invert":       &degradation.Invert,
+		"lighting":     &degradation.Lighting,
+		"blur":         &degradation.Blur,
+		"jpeg":         &degradation.JPEG,
+	})
+	if err != nil || !enabled {
+		return nil, err
+	}
+	fmt.Println("Degradation:", degradation)
+	return &degradation, nil
+}
+
+var previewFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "output, o",
+		Value: "preview.png",
+		Usage: "Save contact sheet to PNG `FILE`",
+	},
+	cli.IntFlag{
+		Name:  "samples, n",
+		Value: 20,
+		Usage: "Number of random images per row",
+	},
+	seedFlag,
+}
+
+func preview(c *cli.Context, render func(fileName string, samples int, rng *rand.Rand) (image.Image, error)) error {
+	if c.Args().First() == "" {
+		return errInputMissing
+	}
+
+	sheet, err := render(c.Args().First(), c.Int("samples"), rand.New(rand.NewSource(genSeed(c))))
+	if err != nil {
+		return err
+	}
+	return draw2dimg.SaveToPngFile(c.String("output"), sheet)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
 func main() {
 	netFlags := []cli.Flag{
@@ -24,6 +454,114 @@ func main() {
 			Name:  "output, o",
 			Usage: "Save network to `FILE`",
 		},
+		cli.StringFlag{
+			Name:  "spec",
+			Usage: "Load architecture of new network from JSON or YAML `FILE`",
+		},
+		cli.StringFlag{
+			Name:  "hidden",
+			Usage: "Comma separated `SIZES` of hidden layers",
+		},
+		cli.StringFlag{
+			Name:  "activation",
+			Usage: "Comma separated `NAMES` of activations of hidden layers (sigmoid, tanh, linear, softmax)",
+		},
+		cli.StringFlag{
+			Name:  "output-activation",
+			Usage: "`NAME` of activation of output layer",
+		},
+		cli.IntFlag{
+			Name:  "epochs",
+			Usage: "Number of training epochs",
+		},
+		cli.IntFlag{
+			Name:  "batch",
+			Usage: "Mini batch size",
+		},
+		cli.Float64Flag{
+			Name:  "lr",
+			Usage: "Learning rate",
+		},
+		cli.Float64Flag{
+			Name:  "l2",
+			Usage: "L2 regularization",
+		},
+		cli.Float64Flag{
+			Name:  "momentum",
+			Usage: "Momentum",
+		},
+		cli.StringFlag{
+			Name:  "cost",
+			Usage: "Cost `FUNCTION` (cross-entropy, log-likelihood, quadratic)",
+		},
+		cli.StringFlag{
+			Name:  "trainer",
+			Usage: "`NAME` of trainer: backprop, or randomized adding noise to samples on each pass",
+		},
+		cli.Float64Flag{
+			Name:  "noise",
+			Usage: "Fraction of input turned white by randomized trainer (grid: empty fragments only)",
+		},
+		cli.Float64Flag{
+			Name:  "validation",
+			Usage: "Fraction of train data of each label held out for validation",
+		},
+		cli.IntFlag{
+			Name:  "folds",
+			Usage: "Cross validate on `K` folds before training on all train data",
+		},
+		cli.Int64Flag{
+			Name:  "split-seed",
+			Usage: "Seed of shuffling train data before it is split and of noise added by randomized trainer",
+		},
+		cli.IntFlag{
+			Name:  "chunk",
+			Usage: "Number of samples converted to network input at once (default 10000)",
+		},
+		cli.BoolFlag{
+			Name:  "stream",
+			Usage: "Read train data from disk on each epoche instead of keeping it in memory",
+		},
+		cli.IntFlag{
+			Name:  "shuffle-buffer",
+			Usage: "Number of streamed samples shuffled together (default 10000)",
+		},
+	}
+
+	formatFlag := cli.StringFlag{
+		Name:  "format",
+		Value: "idx",
+		Usage: "`FORMAT` of converted files, only idx is supported",
+	}
+	exportFlags := []cli.Flag{
+		formatFlag,
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "`PREFIX` of written files, by default name of data file without extension",
+		},
+	}
+	importFlags := []cli.Flag{
+		formatFlag,
+		cli.StringFlag{
+			Name:  "images",
+			Usage: "IDX `FILE` of images, gzip compressed or not",
+		},
+		cli.StringFlag{
+			Name:  "labels",
+			Usage: "IDX `FILE` of labels, gzip compressed or not",
+		},
+		cli.StringFlag{
+			Name:  "label-map",
+			Usage: "Text `FILE` with label of each index of IDX labels, one per line",
+		},
+		cli.BoolFlag{
+			Name:  "transpose",
+			Usage: "Images are stored column by column, like in EMNIST",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Save data to `FILE`",
+		},
 	}
 
 	app := cli.NewApp()
@@ -34,35 +572,417 @@ func main() {
 			Subcommands: []cli.Command{
 				{
 					Name:  "digit",
-					Flags: netFlags,
+					Flags: append(dataFlags(digitgen.TrainFile, digitgen.TestFile), netFlags...),
 					Usage: "Train digit network",
 					Action: func(c *cli.Context) error {
-						nn := digitnet.BuildNN()
+						labels, err := digitnet.FileLabels(c.String("train-file"))
+						if err != nil {
+							return err
+						}
+						spec, err := netSpec(c, digitnet.LabelSpec(labels))
+						if err != nil {
+							return err
+						}
+						spec.Labels = labels
+						training, err := trainingSpec(c, spec, digitnet.DefaultTraining())
+						if err != nil {
+							return err
+						}
+
+						loadSpec := spec
+						if c.String("input") != "" {
+							// Network saved without architecture has outputs for digits 0-9 only
+							loadSpec.Output = len(digitnet.LegacyLabels)
+							loadSpec.Labels = digitnet.LegacyLabels
+						}
+						nn, spec, err := common.LoadNN(c.String("input"), loadSpec)
+						if err != nil {
+							return err
+						}
+						if strings.Join(digitnet.SpecLabels(spec), " ") != strings.Join(labels, " ") {
+							return errLabelMismatch
+						}
+						spec.Labels = labels
+						spec.Training = &training
+						fmt.Println("Network:", spec)
+						fmt.Println("Labels:", strings.Join(labels, " "))
+						fmt.Println("Training:", training)
+
+						if err := digitnet.RunTraining(nn, spec, c.String("train-file"), c.String("test-file")); err != nil {
+							return err
+						}
+						return common.SaveNN(c.String("output"), nn, spec)
+					},
+				},
+				{
+					Name: "grid",
+					Flags: append(append(dataFlags(gridgen.TrainFile, gridgen.TestFile),
+						cli.StringFlag{
+							Name:  "target",
+							Value: gridnet.TargetFragment,
+							Usage: "Train on fragment types (fragment) or fragment super types (super)",
+						},
+					), netFlags...),
+					Usage: "Train grid network",
+					Action: func(c *cli.Context) error {
+						target := c.String("target")
+						defaultSpec, err := gridnet.TargetSpec(target)
+						if err != nil {
+							return err
+						}
+						spec, err := netSpec(c, defaultSpec)
+						if err != nil {
+							return err
+						}
+						spec.Target = target
+						training, err := trainingSpec(c, spec, gridnet.DefaultTraining())
+						if err != nil {
+							return err
+						}
 
-						err := common.LoadNN(c.String("input"), nn)
+						nn, spec, err := common.LoadNN(c.String("input"), spec)
 						if err != nil {
 							return err
 						}
+						if gridnet.SpecTarget(spec) != target {
+							return errTargetMismatch
+						}
+						spec.Training = &training
+						fmt.Println("Network:", spec)
+						fmt.Println("Target:", target)
+						fmt.Println("Training:", training)
 
-						digitnet.RunTraining(nn)
-						common.SaveNN(c.String("output"), nn)
+						if err := gridnet.RunTraining(nn, spec, c.String("train-file"), c.String("test-file")); err != nil {
+							return err
+						}
+						return common.SaveNN(c.String("output"), nn, spec)
+					},
+				},
+			},
+		},
+		{
+			Name:  "eval",
+			Usage: "Evaluating trained network",
+			Subcommands: []cli.Command{
+				{
+					Name:  "digit",
+					Usage: "Evaluate digit network",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "input, i",
+							Usage: "Load network from `FILE`",
+						},
+						cli.StringFlag{
+							Name:  "data",
+							Value: digitgen.TestFile,
+							Usage: "Evaluate on data from `FILE`",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.String("input") == "" {
+							return errInputMissing
+						}
+
+						nn, spec, err := common.LoadNN(c.String("input"), digitnet.DefaultSpec())
+						if err != nil {
+							return err
+						}
+
+						labels := digitnet.SpecLabels(spec)
+						if err := checkSize(spec, digitnet.DefaultSpec().Input, len(labels)); err != nil {
+							return err
+						}
+
+						confusion, err := digitnet.Evaluate(nn, labels, c.String("data"))
+						if err != nil {
+							return err
+						}
+						confusion.Print(os.Stdout)
 						return nil
 					},
 				},
 				{
 					Name:  "grid",
-					Flags: netFlags,
-					Usage: "Train grid network",
+					Usage: "Evaluate grid network",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "input, i",
+							Usage: "Load network from `FILE`",
+						},
+						cli.StringFlag{
+							Name:  "data",
+							Value: gridgen.TestFile,
+							Usage: "Evaluate on data from `FILE`",
+						},
+						cli.StringFlag{
+							Name:  "compare",
+							Usage: "Compare fragment super types found by network with ones of network from `FILE`",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.String("input") == "" {
+							return errInputMissing
+						}
+
+						names := []string{}
+						superConfusions := []*common.Confusion{}
+						for _, fileName := range []string{c.String("input"), c.String("compare")} {
+							if fileName == "" {
+								continue
+							}
+							spec, confusion, superConfusion, err := evalGrid(fileName, c.String("data"))
+							if err != nil {
+								return err
+							}
+							name := fmt.Sprintf("%v (%v)", fileName, gridnet.SpecTarget(spec))
+							names = append(names, name)
+							superConfusions = append(superConfusions, superConfusion)
+
+							fmt.Println("Network:", name)
+							if confusion != nil {
+								fmt.Println("Fragment types")
+								confusion.Print(os.Stdout)
+								fmt.Println()
+							}
+							fmt.Println("Fragment super types")
+							superConfusion.Print(os.Stdout)
+							fmt.Println()
+						}
+
+						if len(superConfusions) > 1 {
+							fmt.Println("Comparison of fragment super types")
+							common.PrintComparison(os.Stdout, names, superConfusions)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "recognize",
+			Usage: "Recognizing images with trained network",
+			Subcommands: []cli.Command{
+				{
+					Name:      "digit",
+					Usage:     "Recognize digit on image",
+					ArgsUsage: "IMAGE",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "input, i",
+							Usage: "Load network from `FILE`",
+						},
+						cli.BoolFlag{
+							Name:  "invert",
+							Usage: "Image has dark digit on light background",
+						},
+					},
 					Action: func(c *cli.Context) error {
-						nn := gridnet.BuildNN()
+						if c.String("input") == "" {
+							return errInputMissing
+						}
+						if c.Args().First() == "" {
+							return errImageMissing
+						}
+
+						nn, spec, err := common.LoadNN(c.String("input"), digitnet.DefaultSpec())
+						if err != nil {
+							return err
+						}
 
-						err := common.LoadNN(c.String("input"), nn)
+						img, err := common.LoadImage(c.Args().First())
 						if err != nil {
 							return err
 						}
+						if c.Bool("invert") {
+							img = common.Invert(common.ToGray(img))
+						}
 
-						gridnet.RunTraining(nn)
-						common.SaveNN(c.String("output"), nn)
+						labels := digitnet.SpecLabels(spec)
+						if err := checkSize(spec, digitnet.DefaultSpec().Input, len(labels)); err != nil {
+							return err
+						}
+						best, output := digitnet.Recognize(nn, img)
+						fmt.Println("Digit:", labels[best])
+						for i, probability := range output {
+							fmt.Printf("%v: %.4f\n", labels[i], probability)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "sudoku",
+			Usage: "Reading and solving sudoku",
+			Subcommands: []cli.Command{
+				{
+					Name:      "read",
+					Usage:     "Read puzzle from photo",
+					ArgsUsage: "IMAGE",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "grid, g",
+							Usage: "Load grid network from `FILE`",
+						},
+						cli.StringFlag{
+							Name:  "digit, d",
+							Usage: "Load digit network from `FILE`",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.String("grid") == "" || c.String("digit") == "" {
+							return errInputMissing
+						}
+						if c.Args().First() == "" {
+							return errImageMissing
+						}
+
+						gridNN, gridSpec, err := common.LoadNN(c.String("grid"), gridnet.DefaultSpec())
+						if err != nil {
+							return err
+						}
+						if gridnet.SpecTarget(gridSpec) != gridnet.TargetFragment {
+							return errGridTarget
+						}
+						digitNN, digitSpec, err := common.LoadNN(c.String("digit"), digitnet.DefaultSpec())
+						if err != nil {
+							return err
+						}
+
+						img, err := common.LoadImage(c.Args().First())
+						if err != nil {
+							return err
+						}
+
+						reader := sudoku.NewReader(gridNN, digitNN)
+						reader.DigitLabels = digitnet.SpecLabels(digitSpec)
+						puzzle, err := reader.Read(img)
+						if err != nil {
+							return err
+						}
+						fmt.Println(puzzle)
+						return nil
+					},
+				},
+				{
+					Name:      "solve",
+					Usage:     "Check and solve puzzle",
+					ArgsUsage: "BOARD",
+					Action: func(c *cli.Context) error {
+						board, err := solver.Parse(c.Args().First())
+						if err != nil {
+							return err
+						}
+
+						conflicts := board.Conflicts()
+						for _, conflict := range conflicts {
+							fmt.Println(conflict)
+						}
+						if len(conflicts) > 0 {
+							return errBrokenRules
+						}
+
+						solution, ok := solver.Solve(board)
+						if !ok {
+							return errNoSolution
+						}
+						fmt.Println(solution)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "data",
+			Usage: "Managing train and test data files",
+			Subcommands: []cli.Command{
+				{
+					Name:  "migrate",
+					Usage: "Convert data files to current version (row-major pixels, full header with label map)",
+					Subcommands: []cli.Command{
+						{
+							Name:      "digit",
+							Usage:     "Digit data files",
+							ArgsUsage: "FILE...",
+							Action: func(c *cli.Context) error {
+								return migrateFiles(c.Args(), digitgen.Migrate)
+							},
+						},
+						{
+							Name:      "grid",
+							Usage:     "Grid data files",
+							ArgsUsage: "FILE...",
+							Action: func(c *cli.Context) error {
+								return migrateFiles(c.Args(), gridgen.Migrate)
+							},
+						},
+					},
+				},
+				{
+					Name:  "export",
+					Usage: "Convert data file to gzip compressed IDX files of images and labels, with label map next to them",
+					Subcommands: []cli.Command{
+						{
+							Name:      "digit",
+							Usage:     "Digit data file",
+							ArgsUsage: "FILE",
+							Flags:     exportFlags,
+							Action: func(c *cli.Context) error {
+								return exportFile(c, digitgen.ExportIDX)
+							},
+						},
+						{
+							Name:      "grid",
+							Usage:     "Grid data file",
+							ArgsUsage: "FILE",
+							Flags:     exportFlags,
+							Action: func(c *cli.Context) error {
+								return exportFile(c, gridgen.ExportIDX)
+							},
+						},
+					},
+				},
+				{
+					Name:  "import",
+					Usage: "Convert IDX files of images and labels (e.g. MNIST, EMNIST) to data file",
+					Subcommands: []cli.Command{
+						{
+							Name:  "digit",
+							Usage: "Digit data file, labeled with digits 0-9 by default",
+							Flags: importFlags,
+							Action: func(c *cli.Context) error {
+								return importFiles(c, digitgen.MnistLabels(), digitgen.ImportIDX)
+							},
+						},
+						{
+							Name:  "grid",
+							Usage: "Grid data file, labeled with fragment types by default",
+							Flags: importFlags,
+							Action: func(c *cli.Context) error {
+								return importFiles(c, gridgen.Labels(), gridgen.ImportIDX)
+							},
+						},
+					},
+				},
+				{
+					Name:      "info",
+					Usage:     "Print header of data files",
+					ArgsUsage: "FILE...",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) == 0 {
+							return errInputMissing
+						}
+						for i, fileName := range c.Args() {
+							header, err := dataset.ReadFileHeader(fileName)
+							if err != nil {
+								return err
+							}
+							if i > 0 {
+								fmt.Println()
+							}
+							fmt.Println("File:", fileName)
+							header.Print(os.Stdout)
+						}
 						return nil
 					},
 				},
@@ -73,23 +993,175 @@ func main() {
 			Usage: "Generating train and test data",
 			Subcommands: []cli.Command{
 				{
-					Name:  "digit",
-					Usage: "Digits",
+					Name:      "digit",
+					Usage:     "Digits",
+					ArgsUsage: "TEXT",
+					Flags: append(append(genFlags(digitgen.DefaultOutDir),
+						cli.BoolFlag{
+							Name:  "stats",
+							Usage: "Print number of train and test records per font type and character",
+						},
+						cli.StringFlag{
+							Name:  "fonts",
+							Value: digitgen.DefaultFontDir,
+							Usage: "Load fonts from `DIR` with hand and machine subdirectories",
+						},
+						cli.StringFlag{
+							Name:  "mnist",
+							Value: digitgen.DefaultMnistDir,
+							Usage: "Load MNIST data set from `DIR`",
+						},
+						cli.BoolFlag{
+							Name:  "no-mnist",
+							Usage: "Leave out MNIST digits, e.g. when drawing letters or other numeral systems",
+						},
+						cli.IntFlag{
+							Name:  "blanks",
+							Value: digitgen.DefaultBlanks,
+							Usage: "Number of images of empty cells",
+						},
+					), augmentFlags...),
 					Action: func(c *cli.Context) error {
+						augmentation, err := parseAugmentation(c)
+						if err != nil {
+							return err
+						}
+
 						text := c.Args().First()
-						return digitgen.GeneratDigits(text)
+						return digitgen.GeneratDigits(text, digitgen.Options{
+							Stats:        c.Bool("stats"),
+							Seed:         genSeed(c),
+							OutDir:       c.String("out"),
+							Force:        c.Bool("force"),
+							FontDir:      c.String("fonts"),
+							MnistDir:     c.String("mnist"),
+							TrainFile:    c.String("train-file"),
+							TestFile:     c.String("test-file"),
+							PNG:          c.Bool("png"),
+							PNGOnly:      c.Bool("png-only"),
+							Augmentation: augmentation,
+							Blanks:       c.Int("blanks"),
+							NoMnist:      c.Bool("no-mnist"),
+						})
+					},
+				},
+				{
+					Name:  "page",
+					Usage: "Whole pages with sudoku boards and their annotations",
+					Flags: []cli.Flag{
+						seedFlag,
+						cli.StringFlag{
+							Name:  "out",
+							Value: pagegen.DefaultOutDir,
+							Usage: "Write pages to `DIR`, it is removed first",
+						},
+						cli.BoolFlag{
+							Name:  "force",
+							Usage: "Remove output directory even if it was not created by generator",
+						},
+						cli.StringFlag{
+							Name:  "fonts",
+							Value: digitgen.DefaultFontDir,
+							Usage: "Load fonts from `DIR` with hand and machine subdirectories",
+						},
+						cli.IntFlag{
+							Name:  "count, n",
+							Value: pagegen.DefaultOptions().Count,
+							Usage: "Number of pages",
+						},
+						cli.Float64Flag{
+							Name:  "givens",
+							Value: pagegen.DefaultOptions().Givens,
+							Usage: "Fraction of cells with printed digits",
+						},
+						cli.Float64Flag{
+							Name:  "handwritten",
+							Value: pagegen.DefaultOptions().Handwritten,
+							Usage: "Fraction of remaining cells filled in by hand",
+						},
+						cli.Float64Flag{
+							Name:  "perspective",
+							Value: pagegen.DefaultOptions().Perspective,
+							Usage: "Longest move of page corner by perspective, in pixels",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return pagegen.GeneratePages(pagegen.Options{
+							Count:       c.Int("count"),
+							Seed:        genSeed(c),
+							OutDir:      c.String("out"),
+							Force:       c.Bool("force"),
+							FontDir:     c.String("fonts"),
+							Givens:      c.Float64("givens"),
+							Handwritten: c.Float64("handwritten"),
+							Perspective: c.Float64("perspective"),
+						})
 					},
 				},
 				{
 					Name:  "grid",
 					Usage: "Fragments of grid",
+					Flags: append(append(genFlags(gridgen.DefaultOutDir),
+						cli.StringFlag{
+							Name:  "pages",
+							Usage: "Add fragments cut out of annotated page images in `DIR`, like those of gen page",
+						},
+						cli.IntFlag{
+							Name:  "page-shifts",
+							Value: 4,
+							Usage: "Number of randomly moved crops around each intersection of page grid",
+						},
+					), degradeFlags...),
+					Action: func(c *cli.Context) error {
+						degradation, err := parseDegradation(c)
+						if err != nil {
+							return err
+						}
+
+						return gridgen.GenerateSudokuGrid(gridgen.Options{
+							Seed:        genSeed(c),
+							OutDir:      c.String("out"),
+							Force:       c.Bool("force"),
+							TrainFile:   c.String("train-file"),
+							TestFile:    c.String("test-file"),
+							PNG:         c.Bool("png"),
+							PNGOnly:     c.Bool("png-only"),
+							Degradation: degradation,
+							PagesDir:    c.String("pages"),
+							PageShifts:  c.Int("page-shifts"),
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "preview",
+			Usage: "Rendering contact sheet of generated data",
+			Subcommands: []cli.Command{
+				{
+					Name:      "digit",
+					Usage:     "Row of digits per font type and character",
+					ArgsUsage: "FILE",
+					Flags:     previewFlags,
 					Action: func(c *cli.Context) error {
-						return gridgen.GenerateSudokuGrid()
+						return preview(c, digitgen.Preview)
+					},
+				},
+				{
+					Name:      "grid",
+					Usage:     "Row of grid fragments per fragment type",
+					ArgsUsage: "FILE",
+					Flags:     previewFlags,
+					Action: func(c *cli.Context) error {
+						return preview(c, gridgen.Preview)
 					},
 				},
 			},
 		},
 	}
 
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,346 @@
+// Package pagegen renders whole pages with sudoku boards along with ground truth annotations,
+// so the whole reading pipeline can be benchmarked.
+package pagegen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/dataset"
+	"github.com/mrfuxi/digit/digitgen"
+	"github.com/mrfuxi/digit/solver"
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+// PageSize is width and height of page image in pixels
+const PageSize = 600
+
+const DefaultOutDir = "out_page"
+
+var ErrNoFonts = errors.New("No machine fonts found")
+var ErrNoSolution = errors.New("Could not build solved board")
+
+var (
+	progress *pb.ProgressBar
+)
+
+// Options of generating pages
+type Options struct {
+	// Number of pages
+	Count int
+	// Seed of random numbers generator. Same seed gives the same pages.
+	Seed int64
+	// Directory for generated pages, removed before generating
+	OutDir string
+	// Remove output directory even if it was not created by generator
+	Force bool
+	// Directory with "hand" and "machine" subdirectories of TTF fonts
+	FontDir string
+	// Fraction of cells with printed digits
+	Givens float64
+	// Fraction of remaining cells filled in by hand
+	Handwritten float64
+	// Longest move of page corner by perspective transformation, in pixels
+	Perspective float64
+}
+
+// DefaultOptions returns options used when none are given
+func DefaultOptions() Options {
+	return Options{
+		Count:       100,
+		OutDir:      DefaultOutDir,
+		FontDir:     digitgen.DefaultFontDir,
+		Givens:      0.35,
+		Handwritten: 0.3,
+		Perspective: 60,
+	}
+}
+
+// Annotation is ground truth of page, saved as JSON next to its image
+type Annotation struct {
+	// Digits visible on page row by row, '.' for empty cells
+	Board string `json:"board"`
+	// Outer corners of board: NW, NE, SE, SW
+	Corners [4][2]float64 `json:"corners"`
+	// Intersections of grid lines row by row, (Size+1) x (Size+1) points
+	Intersections [][2]float64 `json:"intersections,omitempty"`
+	// Cells row by row
+	Cells []CellAnnotation `json:"cells"`
+}
+
+// CellAnnotation describes single cell of board
+type CellAnnotation struct {
+	Row   int `json:"row"`
+	Col   int `json:"col"`
+	Digit int `json:"digit"` // 0 for empty cell
+	// Font type of digit: machine or hand, empty for empty cell
+	Type string `json:"type,omitempty"`
+	// Bounding box of cell on page: left, top, right, bottom
+	Box [4]float64 `json:"box"`
+}
+
+type pageJob struct {
+	Number int
+	Seed   int64
+}
+
+// fontSet holds fonts split by their type
+type fontSet struct {
+	machine []digitgen.Font
+	hand    []digitgen.Font
+}
+
+func newFontSet(fonts []digitgen.Font) (fontSet, error) {
+	set := fontSet{}
+	for _, font := range fonts {
+		switch font.Type {
+		case digitgen.FTypeMachine:
+			set.machine = append(set.machine, font)
+		case digitgen.FTypeHand:
+			set.hand = append(set.hand, font)
+		}
+	}
+	if len(set.machine) == 0 {
+		return set, ErrNoFonts
+	}
+	return set, nil
+}
+
+// pick returns printed font and handwritten font (nil if there are none) used on single page
+func (s fontSet) pick(rng *rand.Rand) (digitgen.Font, *digitgen.Font) {
+	machine := s.machine[rng.Intn(len(s.machine))]
+	if len(s.hand) == 0 {
+		return machine, nil
+	}
+	return machine, &s.hand[rng.Intn(len(s.hand))]
+}
+
+// texture returns paper with uneven brightness and grain
+func texture(rng *rand.Rand) *image.Gray {
+	paper := 190 + rng.Float64()*55
+	blotches := 10 + rng.Float64()*20
+
+	noise := image.NewGray(image.Rect(0, 0, PageSize, PageSize))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(rng.Intn(256))
+	}
+	smooth := common.GaussianBlur(noise, 4+rng.Float64()*4)
+
+	page := image.NewGray(noise.Bounds())
+	for i := range page.Pix {
+		// Blurred noise stays close to the middle gray, stretch it
+		value := paper + (float64(smooth.Pix[i])-128)/8*blotches + (rng.Float64()-0.5)*8
+		page.Pix[i] = uint8(math.Min(255, math.Max(0, value)))
+	}
+	return page
+}
+
+// renderPage draws board on page and returns image with its annotation
+func renderPage(fonts fontSet, options Options, rng *rand.Rand) (image.Image, Annotation, error) {
+	annotation := Annotation{}
+	machineFont, handFont := fonts.pick(rng)
+
+	solution, ok := solver.Solve(solver.Board{})
+	if !ok {
+		return nil, annotation, ErrNoSolution
+	}
+	solution = solver.Shuffle(solution, rng)
+
+	var board solver.Board
+	types := make([]string, solver.Cells)
+	for cell, digit := range solution {
+		if rng.Float64() < options.Givens {
+			board[cell] = digit
+			types[cell] = digitgen.FTypeMachine.String()
+		} else if handFont != nil && rng.Float64() < options.Handwritten {
+			board[cell] = digit
+			types[cell] = digitgen.FTypeHand.String()
+		}
+	}
+
+	// Board position on flat page
+	size := PageSize * (0.55 + rng.Float64()*0.25)
+	left := (PageSize - size) * (0.2 + rng.Float64()*0.6)
+	top := (PageSize - size) * (0.2 + rng.Float64()*0.6)
+	cellSize := size / solver.Size
+
+	canvas := image.NewRGBA(image.Rect(0, 0, PageSize, PageSize))
+	draw.Draw(canvas, canvas.Bounds(), texture(rng), image.ZP, draw.Src)
+	gc := draw2dimg.NewGraphicContext(canvas)
+
+	thin := 1 + rng.Float64()*1.5
+	thick := thin * (1.5 + rng.Float64()*1.5)
+	gc.SetStrokeColor(color.Gray{Y: uint8(rng.Intn(60))})
+	for i := 0; i <= solver.Size; i++ {
+		width := thin
+		if i%solver.BoxSize == 0 {
+			width = thick
+		}
+		pos := float64(i) * cellSize
+		gc.SetLineWidth(width)
+
+		gc.BeginPath()
+		gc.MoveTo(left+pos, top)
+		gc.LineTo(left+pos, top+size)
+		gc.Stroke()
+
+		gc.BeginPath()
+		gc.MoveTo(left, top+pos)
+		gc.LineTo(left+size, top+pos)
+		gc.Stroke()
+	}
+
+	for cell, digit := range board {
+		if digit == 0 {
+			continue
+		}
+
+		font, jitter, ink := machineFont, 0.03, rng.Intn(50)
+		if types[cell] == digitgen.FTypeHand.String() {
+			font, jitter, ink = *handFont, 0.1, 20+rng.Intn(80)
+		}
+		height := cellSize * (0.5 + rng.Float64()*0.2)
+		dx := (rng.Float64()*2 - 1) * jitter * cellSize
+		dy := (rng.Float64()*2 - 1) * jitter * cellSize
+		centerX := left + (float64(cell%solver.Size)+0.5)*cellSize + dx
+		centerY := top + (float64(cell/solver.Size)+0.5)*cellSize + dy
+
+		drawDigit(gc, font, fmt.Sprint(digit), height, centerX, centerY, color.Gray{Y: uint8(ink)})
+	}
+
+	// Perspective of photo, outside of page is dark
+	flat := []common.Point{{X: 0, Y: 0}, {X: PageSize, Y: 0}, {X: PageSize, Y: PageSize}, {X: 0, Y: PageSize}}
+	moved := make([]common.Point, len(flat))
+	for i, corner := range flat {
+		moved[i] = common.Point{
+			X: corner.X + (rng.Float64()*2-1)*options.Perspective,
+			Y: corner.Y + (rng.Float64()*2-1)*options.Perspective,
+		}
+	}
+	h, err := common.FitHomography(flat, moved)
+	if err != nil {
+		return nil, annotation, err
+	}
+	page, err := common.Warp(common.ToGray(canvas), h)
+	if err != nil {
+		return nil, annotation, err
+	}
+
+	annotation.Board = board.String()
+	boardCorners := []common.Point{{X: left, Y: top}, {X: left + size, Y: top}, {X: left + size, Y: top + size}, {X: left, Y: top + size}}
+	for i, corner := range boardCorners {
+		p := h.Apply(corner)
+		annotation.Corners[i] = [2]float64{p.X, p.Y}
+	}
+	for row := 0; row <= solver.Size; row++ {
+		for col := 0; col <= solver.Size; col++ {
+			p := h.Apply(common.Point{X: left + float64(col)*cellSize, Y: top + float64(row)*cellSize})
+			annotation.Intersections = append(annotation.Intersections, [2]float64{p.X, p.Y})
+		}
+	}
+	for cell, digit := range board {
+		row, col := cell/solver.Size, cell%solver.Size
+		x0, y0 := left+float64(col)*cellSize, top+float64(row)*cellSize
+		annotation.Cells = append(annotation.Cells, CellAnnotation{
+			Row:   row,
+			Col:   col,
+			Digit: int(digit),
+			Type:  types[cell],
+			Box:   boundingBox(h, x0, y0, x0+cellSize, y0+cellSize),
+		})
+	}
+
+	return page, annotation, nil
+}
+
+// drawDigit draws digit of given height centered at point
+func drawDigit(gc *draw2dimg.GraphicContext, font digitgen.Font, digit string, height, x, y float64, ink color.Color) {
+	gc.SetFillColor(ink)
+	gc.SetFontData(draw2d.FontData{Name: font.Name})
+	gc.SetFontSize(height)
+
+	// Scale font so digit has expected height
+	left, top, right, bottom := gc.GetStringBounds(digit)
+	if bottom-top > 0 {
+		gc.SetFontSize(height * height / (bottom - top))
+		left, top, right, bottom = gc.GetStringBounds(digit)
+	}
+	gc.FillStringAt(digit, x-(right-left)/2-left, y+(bottom-top)/2-bottom)
+}
+
+// boundingBox returns bounding box of rectangle transformed by homography
+func boundingBox(h common.Homography, x0, y0, x1, y1 float64) [4]float64 {
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, corner := range []common.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}} {
+		p := h.Apply(corner)
+		box[0] = math.Min(box[0], p.X)
+		box[1] = math.Min(box[1], p.Y)
+		box[2] = math.Max(box[2], p.X)
+		box[3] = math.Max(box[3], p.Y)
+	}
+	return box
+}
+
+func prepareJobs(count int, rng *rand.Rand, jobs chan<- pageJob) {
+	for i := 1; i <= count; i++ {
+		jobs <- pageJob{Number: i, Seed: rng.Int63()}
+	}
+}
+
+func pageSaver(fonts fontSet, options Options, jobs <-chan pageJob) {
+	for job := range jobs {
+		page, annotation, err := renderPage(fonts, options, rand.New(rand.NewSource(job.Seed)))
+		if err != nil {
+			panic(err)
+		}
+
+		name := path.Join(options.OutDir, fmt.Sprintf("page-%04d", job.Number))
+		if err := draw2dimg.SaveToPngFile(name+".png", page); err != nil {
+			panic(err)
+		}
+		content, err := json.MarshalIndent(annotation, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(name+".json", append(content, '\n'), 0644); err != nil {
+			panic(err)
+		}
+		progress.Increment()
+	}
+}
+
+// GeneratePages renders pages with sudoku boards as PNG files, each with JSON annotation
+func GeneratePages(options Options) error {
+	loaded, err := digitgen.LoadFonts(options.FontDir)
+	if err != nil {
+		return err
+	}
+	fonts, err := newFontSet(loaded)
+	if err != nil {
+		return err
+	}
+	if err := dataset.PrepareOutDir(options.OutDir, options.Force); err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(options.Seed))
+	jobs := make(chan pageJob, 100)
+
+	progress = pb.StartNew(options.Count)
+	common.RoutineRunner(1, true, func() { prepareJobs(options.Count, rng, jobs) }, func() { close(jobs) })
+	common.RoutineRunner(4, false, func() { pageSaver(fonts, options, jobs) }, nil)
+	progress.Finish()
+
+	return nil
+}
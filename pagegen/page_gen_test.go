@@ -0,0 +1,86 @@
+package pagegen
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/mrfuxi/digit/digitgen"
+	"github.com/mrfuxi/digit/solver"
+)
+
+var testFonts = fontSet{
+	machine: []digitgen.Font{{Name: "machine/test.ttf", Type: digitgen.FTypeMachine}},
+	hand:    []digitgen.Font{{Name: "hand/test.ttf", Type: digitgen.FTypeHand}},
+}
+
+func TestNewFontSet(t *testing.T) {
+	if _, err := newFontSet([]digitgen.Font{{Name: "hand/test.ttf", Type: digitgen.FTypeHand}}); err != ErrNoFonts {
+		t.Errorf("Expected %v, got %v", ErrNoFonts, err)
+	}
+
+	set, err := newFontSet(append(testFonts.machine, testFonts.hand...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.machine) != 1 || len(set.hand) != 1 {
+		t.Errorf("Expected fonts to be split by type, got %v", set)
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	options := DefaultOptions()
+	page, annotation, err := renderPage(testFonts, options, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Bounds().Dx() != PageSize || page.Bounds().Dy() != PageSize {
+		t.Errorf("Expected page of %v pixels, got %v", PageSize, page.Bounds())
+	}
+
+	board, err := solver.Parse(annotation.Board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conflicts := board.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Board breaks rules: %v", conflicts)
+	}
+
+	if len(annotation.Cells) != solver.Cells {
+		t.Fatalf("Expected %v cells, got %v", solver.Cells, len(annotation.Cells))
+	}
+	for i, cell := range annotation.Cells {
+		if cell.Row*solver.Size+cell.Col != i || cell.Digit != int(board[i]) {
+			t.Errorf("Cell %v does not match board: %+v", i, cell)
+		}
+		if (cell.Digit == 0) != (cell.Type == "") {
+			t.Errorf("Cell %v has digit %v of type %q", i, cell.Digit, cell.Type)
+		}
+		if cell.Box[0] >= cell.Box[2] || cell.Box[1] >= cell.Box[3] {
+			t.Errorf("Cell %v has empty box %v", i, cell.Box)
+		}
+	}
+
+	// Top left cell touches NW corner, bottom right one SE corner
+	nw, se := annotation.Corners[0], annotation.Corners[2]
+	first, last := annotation.Cells[0].Box, annotation.Cells[solver.Cells-1].Box
+	if first[0] > nw[0] || first[1] > nw[1] || last[2] < se[0] || last[3] < se[1] {
+		t.Errorf("Corners %v and %v outside of cells %v and %v", nw, se, first, last)
+	}
+	for _, corner := range annotation.Corners {
+		if corner[0] < 0 || corner[1] < 0 || corner[0] > PageSize || corner[1] > PageSize {
+			t.Errorf("Corner %v outside of page", corner)
+		}
+	}
+
+	if len(annotation.Intersections) != (solver.Size+1)*(solver.Size+1) {
+		t.Fatalf("Expected %v intersections, got %v", (solver.Size+1)*(solver.Size+1), len(annotation.Intersections))
+	}
+	if annotation.Intersections[0] != nw || annotation.Intersections[len(annotation.Intersections)-1] != se {
+		t.Errorf("Expected first and last intersections at corners, got %v and %v", annotation.Intersections[0], annotation.Intersections[len(annotation.Intersections)-1])
+	}
+
+	_, again, _ := renderPage(testFonts, options, rand.New(rand.NewSource(1)))
+	if again.Board != annotation.Board || again.Corners != annotation.Corners {
+		t.Error("Expected the same seed to give the same page")
+	}
+}
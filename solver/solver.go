@@ -0,0 +1,338 @@
+package solver
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+const (
+	// Size is number of cells in row, column and box
+	Size = 9
+	// BoxSize is number of cells in row and column of box
+	BoxSize = 3
+	// Cells is number of cells on board
+	Cells = Size * Size
+	// Empty is character used for empty cells
+	Empty = '.'
+)
+
+var ErrBoardSize = errors.New("Board has to have 81 cells")
+var ErrBoardChar = errors.New("Board can contain only digits 1-9 and '.' or '0' for empty cells")
+
+// Board holds digits row by row, 0 means empty cell
+type Board [Cells]uint8
+
+// Parse reads board from string of 81 characters
+func Parse(puzzle string) (board Board, err error) {
+	if len(puzzle) != Cells {
+		return board, ErrBoardSize
+	}
+
+	for i, c := range []byte(puzzle) {
+		switch {
+		case c == Empty || c == '0':
+			board[i] = 0
+		case c >= '1' && c <= '9':
+			board[i] = c - '0'
+		default:
+			return board, ErrBoardChar
+		}
+	}
+	return board, nil
+}
+
+func (b Board) String() string {
+	puzzle := make([]byte, Cells)
+	for i, digit := range b {
+		if digit == 0 {
+			puzzle[i] = Empty
+		} else {
+			puzzle[i] = '0' + digit
+		}
+	}
+	return string(puzzle)
+}
+
+// CellName returns human readable name of cell, i.e. r1c1 for top left one
+func CellName(cell int) string {
+	return fmt.Sprintf("r%dc%d", cell/Size+1, cell%Size+1)
+}
+
+// Unit is a group of cells which has to contain distinct digits
+type Unit struct {
+	Kind  string // row, column or box
+	Index int
+	Cells [Size]int
+}
+
+func (u Unit) String() string {
+	return fmt.Sprintf("%v %v", u.Kind, u.Index+1)
+}
+
+// Units lists all rows, columns and boxes
+var Units = buildUnits()
+
+// cellUnits holds indexes of units each cell belongs to
+var cellUnits = buildCellUnits()
+
+func buildUnits() []Unit {
+	units := make([]Unit, 0, 3*Size)
+	for i := 0; i < Size; i++ {
+		row := Unit{Kind: "row", Index: i}
+		column := Unit{Kind: "column", Index: i}
+		box := Unit{Kind: "box", Index: i}
+		for j := 0; j < Size; j++ {
+			row.Cells[j] = i*Size + j
+			column.Cells[j] = j*Size + i
+			box.Cells[j] = (i/BoxSize*BoxSize+j/BoxSize)*Size + i%BoxSize*BoxSize + j%BoxSize
+		}
+		units = append(units, row, column, box)
+	}
+	return units
+}
+
+func buildCellUnits() (cellUnits [Cells][]int) {
+	for u, unit := range Units {
+		for _, cell := range unit.Cells {
+			cellUnits[cell] = append(cellUnits[cell], u)
+		}
+	}
+	return
+}
+
+// Conflict describes digit repeated in unit
+type Conflict struct {
+	Unit  Unit
+	Digit uint8
+	Cells []int
+}
+
+func (c Conflict) String() string {
+	names := ""
+	for i, cell := range c.Cells {
+		if i > 0 {
+			names += ", "
+		}
+		names += CellName(cell)
+	}
+	return fmt.Sprintf("%v: digit %d repeated in %v", c.Unit, c.Digit, names)
+}
+
+// Conflicts lists all violations of sudoku rules
+func (b Board) Conflicts() []Conflict {
+	var conflicts []Conflict
+	for _, unit := range Units {
+		var seen [Size + 1][]int
+		for _, cell := range unit.Cells {
+			if digit := b[cell]; digit != 0 {
+				seen[digit] = append(seen[digit], cell)
+			}
+		}
+		for digit, cells := range seen {
+			if len(cells) > 1 {
+				conflicts = append(conflicts, Conflict{Unit: unit, Digit: uint8(digit), Cells: cells})
+			}
+		}
+	}
+	return conflicts
+}
+
+// ConflictingCells returns set of cells involved in any conflict
+func (b Board) ConflictingCells() map[int]bool {
+	cells := map[int]bool{}
+	for _, conflict := range b.Conflicts() {
+		for _, cell := range conflict.Cells {
+			cells[cell] = true
+		}
+	}
+	return cells
+}
+
+// candidates is a bit set of digits still possible in cell, bit n is set for digit n
+type candidates uint16
+
+const allCandidates candidates = (1<<(Size+1) - 1) &^ 1
+
+func (c candidates) count() int {
+	cnt := 0
+	for ; c != 0; c &= c - 1 {
+		cnt++
+	}
+	return cnt
+}
+
+func (c candidates) single() uint8 {
+	for digit := uint8(1); digit <= Size; digit++ {
+		if c == 1<<digit {
+			return digit
+		}
+	}
+	return 0
+}
+
+type state struct {
+	board      Board
+	candidates [Cells]candidates
+}
+
+// assign puts digit in cell and removes it from candidates of peers. Returns false on contradiction.
+func (s *state) assign(cell int, digit uint8) bool {
+	if s.candidates[cell]&(1<<digit) == 0 {
+		return false
+	}
+
+	s.board[cell] = digit
+	s.candidates[cell] = 1 << digit
+	for _, u := range cellUnits[cell] {
+		for _, peer := range Units[u].Cells {
+			if peer == cell {
+				continue
+			}
+			if s.board[peer] == digit {
+				return false
+			}
+			s.candidates[peer] &^= 1 << digit
+			if s.candidates[peer] == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// propagate fills cells with only one candidate and digits with only one place in unit.
+// Returns false on contradiction.
+func (s *state) propagate() bool {
+	for changed := true; changed; {
+		changed = false
+
+		for cell := range s.board {
+			if s.board[cell] != 0 {
+				continue
+			}
+			if digit := s.candidates[cell].single(); digit != 0 {
+				if !s.assign(cell, digit) {
+					return false
+				}
+				changed = true
+			}
+		}
+
+		for _, unit := range Units {
+			for digit := uint8(1); digit <= Size; digit++ {
+				places := 0
+				place := 0
+				placed := false
+				for _, cell := range unit.Cells {
+					if s.board[cell] == digit {
+						placed = true
+						break
+					}
+					if s.candidates[cell]&(1<<digit) != 0 {
+						places++
+						place = cell
+					}
+				}
+				if placed {
+					continue
+				}
+				if places == 0 {
+					return false
+				}
+				if places == 1 {
+					if !s.assign(place, digit) {
+						return false
+					}
+					changed = true
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (s *state) search() (Board, bool) {
+	if !s.propagate() {
+		return Board{}, false
+	}
+
+	// Branch on cell with fewest candidates
+	best := -1
+	for cell := range s.board {
+		if s.board[cell] != 0 {
+			continue
+		}
+		if best == -1 || s.candidates[cell].count() < s.candidates[best].count() {
+			best = cell
+		}
+	}
+	if best == -1 {
+		return s.board, true
+	}
+
+	for digit := uint8(1); digit <= Size; digit++ {
+		if s.candidates[best]&(1<<digit) == 0 {
+			continue
+		}
+		next := *s
+		if !next.assign(best, digit) {
+			continue
+		}
+		if solution, ok := next.search(); ok {
+			return solution, true
+		}
+	}
+	return Board{}, false
+}
+
+// Solve finds solution of board using backtracking with constraint propagation.
+// Returns false when board has no solution.
+func Solve(board Board) (Board, bool) {
+	s := state{}
+	for cell := range s.candidates {
+		s.candidates[cell] = allCandidates
+	}
+
+	for cell, digit := range board {
+		if digit == 0 {
+			continue
+		}
+		if digit > Size || !s.assign(cell, digit) {
+			return Board{}, false
+		}
+	}
+
+	return s.search()
+}
+
+// Shuffle returns board transformed in a way which keeps it valid: digits are relabeled,
+// rows and columns are swapped within their bands and stacks, and bands and stacks are reordered
+func Shuffle(board Board, rng *rand.Rand) Board {
+	digits := rng.Perm(Size)
+	rows := shuffledLines(rng)
+	cols := shuffledLines(rng)
+
+	var shuffled Board
+	for row := 0; row < Size; row++ {
+		for col := 0; col < Size; col++ {
+			digit := board[rows[row]*Size+cols[col]]
+			if digit != 0 {
+				digit = uint8(digits[digit-1] + 1)
+			}
+			shuffled[row*Size+col] = digit
+		}
+	}
+	return shuffled
+}
+
+// shuffledLines returns order of rows (or columns) which keeps lines of a band together
+func shuffledLines(rng *rand.Rand) []int {
+	lines := make([]int, 0, Size)
+	for _, band := range rng.Perm(BoxSize) {
+		for _, line := range rng.Perm(BoxSize) {
+			lines = append(lines, band*BoxSize+line)
+		}
+	}
+	return lines
+}
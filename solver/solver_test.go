@@ -0,0 +1,153 @@
+package solver
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const (
+	puzzle   = "53..7....6..195....98....6.8...6...34..8.3..17...2...6.6....28....419..5....8..79"
+	solution = "534678912672195348198342567859761423426853791713924856961537284287419635345286179"
+	// Requires guessing, propagation alone is not enough
+	hardPuzzle = "8..........36......7..9.2...5...7.......457.....1...3...1....68..85...1..9....4.."
+)
+
+func TestParse(t *testing.T) {
+	board, err := Parse(puzzle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board[0] != 5 || board[2] != 0 {
+		t.Errorf("Unexpected board %v", board)
+	}
+	if board.String() != puzzle {
+		t.Errorf("Expected %v, got %v", puzzle, board.String())
+	}
+
+	zeros, err := Parse("0" + puzzle[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zeros[0] != 0 {
+		t.Errorf("Expected 0 to be empty cell, got %v", zeros[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse(puzzle[1:]); err != ErrBoardSize {
+		t.Errorf("Expected %v, got %v", ErrBoardSize, err)
+	}
+	if _, err := Parse("x" + puzzle[1:]); err != ErrBoardChar {
+		t.Errorf("Expected %v, got %v", ErrBoardChar, err)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	for _, p := range []string{puzzle, hardPuzzle} {
+		board, _ := Parse(p)
+		solved, ok := Solve(board)
+		if !ok {
+			t.Fatalf("Expected %v to be solved", p)
+		}
+		if len(solved.Conflicts()) != 0 {
+			t.Errorf("Solution %v breaks rules", solved)
+		}
+		for i := range board {
+			if board[i] != 0 && board[i] != solved[i] {
+				t.Errorf("Solution %v changed given digit in %v", solved, CellName(i))
+			}
+			if solved[i] == 0 {
+				t.Errorf("Solution %v is incomplete", solved)
+			}
+		}
+	}
+
+	board, _ := Parse(puzzle)
+	solved, _ := Solve(board)
+	if solved.String() != solution {
+		t.Errorf("Expected %v, got %v", solution, solved)
+	}
+}
+
+func TestSolveUnsolvable(t *testing.T) {
+	// Two 5s in first row
+	board, _ := Parse("55..7....6..195....98....6.8...6...34..8.3..17...2...6.6....28....419..5....8..79")
+	if _, ok := Solve(board); ok {
+		t.Error("Expected board with conflict to have no solution")
+	}
+
+	// No conflicts but first cell has no candidates left
+	board, _ = Parse(".23456789" + "........." + "........." + "1........" + "........." + "........." + "........." + "........." + ".........")
+	if _, ok := Solve(board); ok {
+		t.Error("Expected board without candidates for r1c1 to have no solution")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	board, _ := Parse(puzzle)
+	if conflicts := board.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+
+	// r1c3 = 5 conflicts with r1c1 in row 1 and box 1
+	board[2] = 5
+	conflicts := board.Conflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %v", conflicts)
+	}
+	for _, conflict := range conflicts {
+		if conflict.Digit != 5 || len(conflict.Cells) != 2 {
+			t.Errorf("Unexpected conflict %v", conflict)
+		}
+	}
+	if conflicts[0].String() != "row 1: digit 5 repeated in r1c1, r1c3" {
+		t.Errorf("Unexpected description %q", conflicts[0].String())
+	}
+
+	cells := board.ConflictingCells()
+	if len(cells) != 2 || !cells[0] || !cells[2] {
+		t.Errorf("Unexpected conflicting cells %v", cells)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	if len(Units) != 3*Size {
+		t.Fatalf("Expected %v units, got %v", 3*Size, len(Units))
+	}
+
+	for cell := 0; cell < Cells; cell++ {
+		if len(cellUnits[cell]) != 3 {
+			t.Errorf("Cell %v belongs to %v units", CellName(cell), len(cellUnits[cell]))
+		}
+	}
+
+	// Box 4 is the middle one
+	for _, unit := range Units {
+		if unit.Kind == "box" && unit.Index == 4 && (unit.Cells[0] != 30 || unit.Cells[8] != 50) {
+			t.Errorf("Unexpected cells of middle box %v", unit.Cells)
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	board, _ := Parse(solution)
+	rng := rand.New(rand.NewSource(1))
+
+	shuffled := Shuffle(board, rng)
+	if shuffled == board {
+		t.Error("Expected board to change")
+	}
+	if conflicts := shuffled.Conflicts(); len(conflicts) != 0 {
+		t.Errorf("Shuffled board %v breaks rules: %v", shuffled, conflicts)
+	}
+	for i, digit := range shuffled {
+		if digit == 0 {
+			t.Errorf("Shuffled board %v has empty cell %v", shuffled, CellName(i))
+		}
+	}
+
+	empty := Shuffle(Board{}, rng)
+	if empty != (Board{}) {
+		t.Errorf("Expected empty board to stay empty, got %v", empty)
+	}
+}
@@ -0,0 +1,81 @@
+package sudoku
+
+import (
+	"sort"
+
+	"github.com/mrfuxi/digit/solver"
+)
+
+const (
+	// Maximal number of cells changed when correcting recognized digits
+	maxCorrections = 3
+	// Number of less probable digits tried in each cell
+	maxAlternatives = 3
+)
+
+// Board returns board with most probable digit in each cell
+func Board(cells []Cell) (board solver.Board) {
+	for i, cell := range cells {
+		board[i] = uint8(cell.Digit())
+	}
+	return
+}
+
+// Correct returns board read from cells. When most probable digits break sudoku rules,
+// less probable digits are tried in conflicting cells until board can be solved.
+// Returns false if no correction was found, together with board of most probable digits.
+func Correct(cells []Cell) (solver.Board, bool) {
+	board := Board(cells)
+	if corrected, ok := correct(board, cells, maxCorrections); ok {
+		return corrected, true
+	}
+	return board, false
+}
+
+func correct(board solver.Board, cells []Cell, depth int) (solver.Board, bool) {
+	conflicting := board.ConflictingCells()
+	if len(conflicting) == 0 {
+		_, ok := solver.Solve(board)
+		return board, ok
+	}
+	if depth == 0 {
+		return board, false
+	}
+
+	// Least confident readings are most likely wrong
+	suspects := make([]int, 0, len(conflicting))
+	for cell := range conflicting {
+		suspects = append(suspects, cell)
+	}
+	sort.Slice(suspects, func(i, j int) bool {
+		ci, cj := suspects[i], suspects[j]
+		pi, pj := cells[ci].Output[board[ci]], cells[cj].Output[board[cj]]
+		if pi != pj {
+			return pi < pj
+		}
+		return ci < cj
+	})
+
+	for _, cell := range suspects {
+		tried := 0
+		for _, digit := range cells[cell].Candidates() {
+			if uint8(digit) == board[cell] {
+				continue
+			}
+			if tried == maxAlternatives {
+				break
+			}
+			tried++
+
+			next := board
+			next[cell] = uint8(digit)
+			if len(next.ConflictingCells()) >= len(conflicting) {
+				continue
+			}
+			if corrected, ok := correct(next, cells, depth-1); ok {
+				return corrected, true
+			}
+		}
+	}
+	return board, false
+}
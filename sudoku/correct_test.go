@@ -0,0 +1,67 @@
+package sudoku
+
+import (
+	"testing"
+)
+
+const puzzle = "53..7....6..195....98....6.8...6...34..8.3..17...2...6.6....28....419..5....8..79"
+
+// readCells simulates recognized puzzle, each digit read with certainty
+func readCells(puzzle string) []Cell {
+	cells := make([]Cell, len(puzzle))
+	for i, c := range puzzle {
+		if c == '.' {
+			cells[i].Empty = true
+			continue
+		}
+		cells[i].Output = make([]float64, 10)
+		cells[i].Output[c-'0'] = 0.9
+	}
+	return cells
+}
+
+func TestBoard(t *testing.T) {
+	if board := Board(readCells(puzzle)); board.String() != puzzle {
+		t.Errorf("Expected %v, got %v", puzzle, board)
+	}
+}
+
+func TestCorrectValid(t *testing.T) {
+	board, ok := Correct(readCells(puzzle))
+	if !ok {
+		t.Fatal("Expected valid puzzle to be accepted")
+	}
+	if board.String() != puzzle {
+		t.Errorf("Expected %v, got %v", puzzle, board)
+	}
+}
+
+func TestCorrectMisread(t *testing.T) {
+	cells := readCells(puzzle)
+	// First 5 read as 3 (conflicts with 3 next to it), 5 is the second choice
+	cells[0].Output = []float64{0, 0, 0.1, 0.5, 0, 0.4, 0, 0, 0, 0}
+	// Second 6 read as 8 (conflicts with 8 below it), 6 is the third choice
+	cells[9].Output = []float64{0, 0, 0, 0, 0, 0.2, 0.25, 0, 0.55, 0}
+
+	board, ok := Correct(cells)
+	if !ok {
+		t.Fatal("Expected misread puzzle to be corrected")
+	}
+	if board.String() != puzzle {
+		t.Errorf("Expected %v, got %v", puzzle, board)
+	}
+}
+
+func TestCorrectImpossible(t *testing.T) {
+	cells := readCells(puzzle)
+	// Empty cell read as 1, does not break rules but makes puzzle unsolvable
+	cells[2] = Cell{Output: []float64{0, 0.9, 0, 0, 0, 0, 0, 0, 0, 0}}
+
+	board, ok := Correct(cells)
+	if ok {
+		t.Fatalf("Expected correction to fail, got %v", board)
+	}
+	if board[2] != 1 {
+		t.Errorf("Expected most probable digits to be returned, got %v", board)
+	}
+}
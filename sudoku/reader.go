@@ -0,0 +1,340 @@
+package sudoku
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/digitgen"
+	"github.com/mrfuxi/digit/digitnet"
+	"github.com/mrfuxi/digit/gridgen"
+	"github.com/mrfuxi/digit/gridnet"
+	"github.com/mrfuxi/neural"
+)
+
+// Size is number of cells in row, column and box
+const Size = 9
+
+const (
+	// Size of cell after scaling photo, so intersections of lines fit in grid network window
+	workingCellSize = gridgen.ImageSize
+	windowStride    = 2
+	// Detections closer than that (in working scale) are considered the same intersection
+	suppressRadius = 6.0
+	// Part of cell cut off on each side, so grid lines do not end up in digit image
+	cellMargin = 0.12
+	// Minimal contrast and amount of ink in center of cell to consider it not empty
+	minCellContrast = 64
+	minCellInk      = 0.03
+)
+
+var ErrGridNotFound = errors.New("Grid not found")
+
+// Fractions of shorter side of photo taken by grid. Each is tried and best fit is used.
+var gridFractions = []float64{1, 0.9, 0.8, 0.7, 0.6, 0.5, 0.4}
+
+// Cell read from photo
+type Cell struct {
+	Empty  bool
+	Output []float64 // Probabilities of digits 0-9 given by digit network, nil for empty cell
+}
+
+// Digit returns most probable digit (1-9), 0 for empty cell
+func (c Cell) Digit() int {
+	if c.Empty {
+		return 0
+	}
+	return c.Candidates()[0]
+}
+
+// Candidates returns digits 1-9 ordered from most to least probable
+func (c Cell) Candidates() []int {
+	candidates := make([]int, 0, Size)
+	for digit := 1; digit <= Size && digit < len(c.Output); digit++ {
+		candidates = append(candidates, digit)
+	}
+	for i := 1; i < len(candidates); i++ {
+		for j := i; j > 0 && c.Output[candidates[j]] > c.Output[candidates[j-1]]; j-- {
+			candidates[j], candidates[j-1] = candidates[j-1], candidates[j]
+		}
+	}
+	return candidates
+}
+
+type detection struct {
+	common.Point
+	Fragment gridgen.FragmentType
+	Score    float64
+}
+
+// Reader finds sudoku grid on photo and reads digits from it
+type Reader struct {
+	Grid  neural.Evaluator
+	Digit neural.Evaluator
+	// Labels of digit network outputs
+	DigitLabels []string
+	// Minimal grid network output to accept intersection of lines
+	Threshold float64
+}
+
+// NewReader creates Reader using given grid and digit networks
+func NewReader(grid, digit neural.Evaluator) *Reader {
+	return &Reader{
+		Grid:        grid,
+		Digit:       digit,
+		DigitLabels: digitnet.LegacyLabels,
+		Threshold:   0.5,
+	}
+}
+
+// Read returns puzzle string (81 characters) read from photo.
+// Digits breaking sudoku rules are replaced with less probable ones when it makes puzzle solvable.
+func (r *Reader) Read(img image.Image) (string, error) {
+	cells, err := r.ReadCells(img)
+	if err != nil {
+		return "", err
+	}
+	board, _ := Correct(cells)
+	return board.String(), nil
+}
+
+// ReadCells returns all cells read from photo, row by row
+func (r *Reader) ReadCells(img image.Image) ([]Cell, error) {
+	gray := common.LightOnDark(common.ToGray(img))
+
+	lattice, err := r.FindGrid(gray)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]Cell, 0, Size*Size)
+	for row := 0; row < Size; row++ {
+		for col := 0; col < Size; col++ {
+			cellImg, empty := warpCell(gray, lattice, col, row)
+			cell := Cell{Empty: empty}
+			if !empty {
+				output, blank := digitnet.DigitOutput(r.DigitLabels, r.Digit.Evaluate(digitnet.ImageInput(cellImg)))
+				cell = Cell{Empty: blank}
+				if !blank {
+					cell.Output = output
+				}
+			}
+			cells = append(cells, cell)
+		}
+	}
+	return cells, nil
+}
+
+// FindGrid returns transformation from lattice coordinates (0-9 on both axes) to photo coordinates.
+// Photo is expected to have light lines on dark background.
+func (r *Reader) FindGrid(gray *image.Gray) (common.Homography, error) {
+	bounds := gray.Bounds()
+	shorter := math.Min(float64(bounds.Dx()), float64(bounds.Dy()))
+
+	var best common.Homography
+	bestScore := 0
+	for _, fraction := range gridFractions {
+		scale := Size * workingCellSize / (shorter * fraction)
+		detections := r.detect(gray, scale)
+
+		lattice, score, err := fitLattice(detections, scale)
+		if err != nil {
+			continue
+		}
+		if score > bestScore {
+			best = lattice
+			bestScore = score
+		}
+	}
+
+	if bestScore == 0 {
+		return common.Homography{}, ErrGridNotFound
+	}
+	return best, nil
+}
+
+// detect slides grid network over scaled photo and returns intersections in photo coordinates
+func (r *Reader) detect(gray *image.Gray, scale float64) []detection {
+	bounds := gray.Bounds()
+	width := int(float64(bounds.Dx()) * scale)
+	height := int(float64(bounds.Dy()) * scale)
+	if width < gridgen.ImageSize || height < gridgen.ImageSize {
+		return nil
+	}
+	scaled := common.Resize(gray, width, height)
+
+	var candidates []detection
+	for y := 0; y+gridgen.ImageSize <= height; y += windowStride {
+		for x := 0; x+gridgen.ImageSize <= width; x += windowStride {
+			window := scaled.SubImage(image.Rect(x, y, x+gridgen.ImageSize, y+gridgen.ImageSize))
+			output := r.Grid.Evaluate(gridnet.ImageInput(window))
+			fragment := gridgen.FragmentType(common.Argmax(output))
+			if gridgen.IsEmpty(fragment) || output[fragment] < r.Threshold {
+				continue
+			}
+
+			center := gridgen.ImageSize / 2.0
+			candidates = append(candidates, detection{
+				Point:    common.Point{X: (float64(x) + center) / scale, Y: (float64(y) + center) / scale},
+				Fragment: fragment,
+				Score:    output[fragment],
+			})
+		}
+	}
+
+	return suppress(candidates, suppressRadius/scale)
+}
+
+// suppress keeps only best scored detection in its neighbourhood
+func suppress(candidates []detection, radius float64) []detection {
+	var kept []detection
+	for _, candidate := range candidates {
+		merged := false
+		for i := range kept {
+			if kept[i].Dist(candidate.Point) > radius {
+				continue
+			}
+			if candidate.Score > kept[i].Score {
+				kept[i] = candidate
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// outerCorners picks most outer corner of each type
+func outerCorners(detections []detection) ([]common.Point, error) {
+	// Direction in which corner of given type is most outer
+	directions := []struct {
+		fragment gridgen.FragmentType
+		dx, dy   float64
+	}{
+		{gridgen.FragmentTypeCornerNW, -1, -1},
+		{gridgen.FragmentTypeCornerNE, 1, -1},
+		{gridgen.FragmentTypeCornerSE, 1, 1},
+		{gridgen.FragmentTypeCornerSW, -1, 1},
+	}
+
+	corners := make([]common.Point, 0, len(directions))
+	for _, direction := range directions {
+		found := false
+		var best common.Point
+		for _, d := range detections {
+			if d.Fragment != direction.fragment {
+				continue
+			}
+			if !found || d.X*direction.dx+d.Y*direction.dy > best.X*direction.dx+best.Y*direction.dy {
+				best = d.Point
+				found = true
+			}
+		}
+		if !found {
+			return nil, ErrGridNotFound
+		}
+		corners = append(corners, best)
+	}
+	return corners, nil
+}
+
+// fitLattice finds transformation from lattice coordinates to photo coordinates
+// and returns number of intersections matching it
+func fitLattice(detections []detection, scale float64) (common.Homography, int, error) {
+	corners, err := outerCorners(detections)
+	if err != nil {
+		return common.Homography{}, 0, err
+	}
+
+	lattice, err := common.FitHomography(
+		[]common.Point{{X: 0, Y: 0}, {X: Size, Y: 0}, {X: Size, Y: Size}, {X: 0, Y: Size}},
+		corners,
+	)
+	if err != nil {
+		return common.Homography{}, 0, err
+	}
+
+	// Refine lattice using all intersections, not only corners
+	radius := workingCellSize / 3.0 / scale
+	matched := 0
+	for iteration := 0; iteration < 2; iteration++ {
+		var src, dst []common.Point
+		for row := 0; row <= Size; row++ {
+			for col := 0; col <= Size; col++ {
+				point := common.Point{X: float64(col), Y: float64(row)}
+				expected := lattice.Apply(point)
+				fragment := gridgen.LatticeFragment(col, row, Size)
+
+				found := false
+				var best common.Point
+				for _, d := range detections {
+					if gridgen.FragmentTypeToSuper(d.Fragment) != gridgen.FragmentTypeToSuper(fragment) {
+						continue
+					}
+					if dist := d.Dist(expected); dist < radius && (!found || dist < best.Dist(expected)) {
+						best = d.Point
+						found = true
+					}
+				}
+				if found {
+					src = append(src, point)
+					dst = append(dst, best)
+				}
+			}
+		}
+
+		matched = len(src)
+		refined, err := common.FitHomography(src, dst)
+		if err != nil {
+			break
+		}
+		lattice = refined
+	}
+
+	return lattice, matched, nil
+}
+
+// warpCell cuts cell out of photo and straightens it. Returns if cell is empty.
+func warpCell(gray *image.Gray, lattice common.Homography, col, row int) (*image.Gray, bool) {
+	size := digitgen.ImageSize
+	cell := image.NewGray(image.Rect(0, 0, size, size))
+
+	minPix, maxPix := uint8(255), uint8(0)
+	for v := 0; v < size; v++ {
+		for u := 0; u < size; u++ {
+			point := common.Point{
+				X: float64(col) + cellMargin + (float64(u)+0.5)/float64(size)*(1-2*cellMargin),
+				Y: float64(row) + cellMargin + (float64(v)+0.5)/float64(size)*(1-2*cellMargin),
+			}
+			pix := common.BilinearGray(gray, lattice.Apply(point))
+			cell.SetGray(u, v, color.Gray{Y: pix})
+			if pix < minPix {
+				minPix = pix
+			}
+			if pix > maxPix {
+				maxPix = pix
+			}
+		}
+	}
+
+	if int(maxPix)-int(minPix) < minCellContrast {
+		return cell, true
+	}
+
+	// Stretch contrast, so background is black and ink is white
+	ink := 0
+	for i, pix := range cell.Pix {
+		cell.Pix[i] = uint8((int(pix) - int(minPix)) * 255 / (int(maxPix) - int(minPix)))
+		x, y := i%size, i/size
+		if x >= size/4 && x < size*3/4 && y >= size/4 && y < size*3/4 && cell.Pix[i] > 127 {
+			ink++
+		}
+	}
+
+	return cell, float64(ink)/float64(size*size/4) < minCellInk
+}
@@ -0,0 +1,86 @@
+package sudoku
+
+import (
+	"testing"
+
+	"github.com/mrfuxi/digit/common"
+	"github.com/mrfuxi/digit/gridgen"
+)
+
+func TestCellCandidates(t *testing.T) {
+	cell := Cell{Output: []float64{0.5, 0.01, 0.02, 0.3, 0.04, 0.05, 0.06, 0.07, 0.08, 0.09}}
+
+	candidates := cell.Candidates()
+	expected := []int{3, 9, 8, 7, 6, 5, 4, 2, 1}
+	if len(candidates) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, candidates)
+	}
+	for i := range expected {
+		if candidates[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, candidates)
+		}
+	}
+
+	if digit := cell.Digit(); digit != 3 {
+		t.Errorf("Expected digit 3 (0 is not valid in sudoku), got %v", digit)
+	}
+}
+
+func TestFitLattice(t *testing.T) {
+	expected := common.Homography{30, 2, 40, -1, 28, 35, 0.0005, 0.0003, 1}
+
+	var detections []detection
+	for row := 0; row <= Size; row++ {
+		for col := 0; col <= Size; col++ {
+			p := expected.Apply(common.Point{X: float64(col), Y: float64(row)})
+			detections = append(detections, detection{
+				Point:    common.Point{X: p.X + float64(col%2), Y: p.Y - float64(row%2)},
+				Fragment: gridgen.LatticeFragment(col, row, Size),
+				Score:    0.9,
+			})
+		}
+	}
+	// Noise outside of grid should not be picked as corner
+	detections = append(detections, detection{Point: common.Point{X: 500, Y: 500}, Fragment: gridgen.FragmentTypeCross, Score: 1})
+
+	lattice, score, err := fitLattice(detections, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != (Size+1)*(Size+1) {
+		t.Errorf("Expected all intersections to match, got %v", score)
+	}
+
+	center := common.Point{X: 4.5, Y: 4.5}
+	if dist := lattice.Apply(center).Dist(expected.Apply(center)); dist > 1 {
+		t.Errorf("Lattice center off by %v", dist)
+	}
+}
+
+func TestFitLatticeMissingCorner(t *testing.T) {
+	detections := []detection{
+		{Point: common.Point{X: 0, Y: 0}, Fragment: gridgen.FragmentTypeCornerNW},
+		{Point: common.Point{X: 100, Y: 0}, Fragment: gridgen.FragmentTypeCornerNE},
+		{Point: common.Point{X: 100, Y: 100}, Fragment: gridgen.FragmentTypeCornerSE},
+	}
+
+	if _, _, err := fitLattice(detections, 1); err != ErrGridNotFound {
+		t.Errorf("Expected %v, got %v", ErrGridNotFound, err)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	candidates := []detection{
+		{Point: common.Point{X: 10, Y: 10}, Score: 0.6},
+		{Point: common.Point{X: 12, Y: 10}, Score: 0.9},
+		{Point: common.Point{X: 50, Y: 50}, Score: 0.7},
+	}
+
+	kept := suppress(candidates, 5)
+	if len(kept) != 2 {
+		t.Fatalf("Expected 2 detections, got %v", kept)
+	}
+	if kept[0].Score != 0.9 {
+		t.Errorf("Expected best detection to be kept, got %v", kept[0])
+	}
+}